@@ -1,6 +1,10 @@
 package asset
 
-import "net/http"
+import (
+	"fmt"
+	"net/http"
+	"sync"
+)
 
 // AssetFS is an interface to a grouping of binary assets created by the pack
 // tool providing functionality for retrieving contained assets and information
@@ -26,14 +30,16 @@ type Assets interface {
 // provided AssetFSs.
 func New(af ...AssetFS) Assets {
 	as := &assets{
-		a: make([]AssetFS, 0),
+		a: make([]layer, 0),
 	}
 	as.SetAssetFS(af...)
 	return as
 }
 
 type assets struct {
-	a []AssetFS
+	mu    sync.RWMutex
+	a     []layer
+	added int
 }
 
 var assetUnavailable = Xrror("Asset %s unavailable").Out
@@ -41,7 +47,7 @@ var assetUnavailable = Xrror("Asset %s unavailable").Out
 // The default assets GetAssets function takes a string name and returns an
 // http.File version of the assset if it exists and an error.
 func (a *assets) GetAsset(requested string) (http.File, error) {
-	for _, x := range a.a {
+	for _, x := range a.ListLayers() {
 		f, err := x.AssetHttp(requested)
 		if err == nil {
 			return f, nil
@@ -53,7 +59,7 @@ func (a *assets) GetAsset(requested string) (http.File, error) {
 // The default assets GetAssetByte function takes a string name and returns a
 // byte representation of the asset if it exists and error.
 func (a *assets) GetAssetByte(requested string) ([]byte, error) {
-	for _, x := range a.a {
+	for _, x := range a.ListLayers() {
 		b, err := x.Asset(requested)
 		if err == nil {
 			return b, nil
@@ -63,13 +69,22 @@ func (a *assets) GetAssetByte(requested string) ([]byte, error) {
 }
 
 // The default assets SetAssetFS function takes any number of AssetFS to add to
-// the assets instance.
+// the assets instance, as layers of priority 0 named assetfs-1, assetfs-2 and
+// so on in the order added.
 func (a *assets) SetAssetFS(af ...AssetFS) {
-	a.a = append(a.a, af...)
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for _, x := range af {
+		a.set(fmt.Sprintf("assetfs-%d", a.added+1), 0, x)
+	}
 }
 
 // The default assets ListAssetFS function returns a slice of AssetFS contained
-// in the assets instance.
+// in the assets instance, in the order assets are looked for in them.
 func (a *assets) ListAssetFS() []AssetFS {
-	return a.a
+	var ret []AssetFS
+	for _, l := range a.ListLayers() {
+		ret = append(ret, l.AssetFS)
+	}
+	return ret
 }
@@ -1,9 +1,18 @@
 package asset_test
 
 import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/flxtilla/app"
+	"github.com/flxtilla/cxre/asset"
 	"github.com/flxtilla/cxre/asset/test/resources"
 )
 
@@ -48,3 +57,120 @@ func TestAssets(t *testing.T) {
 		t.Errorf("Asset list length is %d, not 4: %s", ll, l)
 	}
 }
+
+func TestManifest(t *testing.T) {
+	m := make(asset.Manifest)
+	fp := m.Add("css/app.css", []byte("body {}"))
+	if !strings.HasPrefix(fp, "css/app.") || !strings.HasSuffix(fp, ".css") || len(fp) != len("css/app.css")+asset.FingerprintLength+1 {
+		t.Errorf(`Fingerprinted name was %q`, fp)
+	}
+	if again := asset.FingerprintName("css/app.css", []byte("body {}")); again != fp {
+		t.Errorf(`Fingerprinted name of the same content was %q, not %q`, again, fp)
+	}
+	if other := asset.FingerprintName("css/app.css", []byte("p {}")); other == fp {
+		t.Error("Fingerprinted name of other content was the same")
+	}
+	for _, name := range []string{"LICENSE", "css/.htaccess"} {
+		if n := m.Add(name, []byte("x")); n != name {
+			t.Errorf(`Fingerprinted name of %q was %q`, name, n)
+		}
+	}
+	if o := m.Original(); len(o) != 1 || o[fp] != "css/app.css" {
+		t.Errorf(`Original names were %v`, o)
+	}
+
+	var b bytes.Buffer
+	if _, err := m.WriteTo(&b); err != nil {
+		t.Fatalf("WriteTo error: %s", err)
+	}
+	read, err := asset.ReadManifest(&b, "manifest.json")
+	if err != nil {
+		t.Fatalf("ReadManifest error: %s", err)
+	}
+	if !reflect.DeepEqual(read, m) {
+		t.Errorf(`Read manifest was %v, not %v`, read, m)
+	}
+	if _, err := asset.ReadManifest(strings.NewReader("["), "bad.json"); err == nil {
+		t.Error("ReadManifest of a bad manifest did not return an error")
+	}
+}
+
+type testAssetFS map[string]string
+
+func (t testAssetFS) Asset(name string) ([]byte, error) {
+	if v, ok := t[name]; ok {
+		return []byte(v), nil
+	}
+	return nil, errors.New("not found")
+}
+
+func (t testAssetFS) AssetHttp(name string) (http.File, error) {
+	if _, ok := t[name]; ok {
+		return nil, errors.New("not an http.File")
+	}
+	return nil, errors.New("not found")
+}
+
+func (t testAssetFS) AssetDir(string) ([]string, error) {
+	return nil, errors.New("not a directory")
+}
+
+func (t testAssetFS) AssetNames() []string {
+	var ret []string
+	for k := range t {
+		ret = append(ret, k)
+	}
+	return ret
+}
+
+func TestLayers(t *testing.T) {
+	a := asset.New(testAssetFS{"theme.css": "packed theme", "app.js": "packed app"})
+	l := a.(asset.Layers)
+	l.SetLayer("vendor", -1, testAssetFS{"theme.css": "vendored theme", "vendor.js": "vendored"})
+	l.SetLayer("theme", 10, testAssetFS{"theme.css": "custom theme"})
+
+	var names []string
+	for _, x := range l.ListLayers() {
+		names = append(names, x.Name)
+	}
+	if expected := []string{"theme", "assetfs-1", "vendor"}; !reflect.DeepEqual(names, expected) {
+		t.Errorf("Layers were %v, not %v", names, expected)
+	}
+	for name, expected := range map[string]string{
+		"theme.css": "custom theme",
+		"app.js":    "packed app",
+		"vendor.js": "vendored",
+	} {
+		if b, err := a.GetAssetByte(name); err != nil || string(b) != expected {
+			t.Errorf("GetAssetByte(%q) was %q, %v", name, b, err)
+		}
+	}
+	if !l.RemoveLayer("theme") || l.RemoveLayer("theme") {
+		t.Error("RemoveLayer did not remove the layer once")
+	}
+
+	dir, err := ioutil.TempDir("", "overlay")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	ioutil.WriteFile(filepath.Join(dir, "theme.css"), []byte("overlaid theme"), 0644)
+	l.Overlay(dir)
+	if b, err := a.GetAssetByte("theme.css"); err != nil || string(b) != "overlaid theme" {
+		t.Errorf("Overlaid GetAssetByte was %q, %v", b, err)
+	}
+	if r, err := l.Resolve("theme.css"); err != nil || r.Name != "overlay:"+dir {
+		t.Errorf("Resolve was %q, %v", r.Name, err)
+	}
+	if f, err := a.GetAsset("theme.css"); err != nil {
+		t.Errorf("Overlaid GetAsset error: %s", err)
+	} else {
+		f.Close()
+	}
+	if _, err := l.Resolve("missing.css"); err == nil {
+		t.Error("Resolve of a missing asset did not return an error")
+	}
+	if names := asset.DirFS(dir).AssetNames(); !reflect.DeepEqual(names, []string{"theme.css"}) {
+		t.Errorf("DirFS AssetNames were %v", names)
+	}
+}
@@ -2,5 +2,7 @@
 // assets in a flotilla application. These include the Assets interface used by
 // the the application environment, the AssetFS interface used by Assets and
 // potentially coming from various directions, the pack package(a fork of
-// go-bindata) and pack tool used to create AssetFS.
+// go-bindata) and pack tool used to create AssetFS. With Go 1.16 any fs.FS,
+// e.g. an embed.FS, may be used as an AssetFS, and Assets used as an fs.FS or
+// http.FileSystem.
 package asset
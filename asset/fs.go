@@ -0,0 +1,263 @@
+//go:build go1.16
+// +build go1.16
+
+package asset
+
+import (
+	"bytes"
+	"io"
+	"io/fs"
+	"net/http"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// FromFS returns an AssetFS of the files of any fs.FS, e.g. an embed.FS of
+// files embedded with //go:embed. Asset names are the slash separated paths
+// of the files in the FS.
+func FromFS(fsys fs.FS) AssetFS {
+	return &fsAssetFS{fsys}
+}
+
+// SubFS returns an AssetFS of the files beneath a directory of an fs.FS, e.g.
+// resources of an embed.FS embedding resources/..., with names relative to
+// the directory.
+func SubFS(fsys fs.FS, dir string) (AssetFS, error) {
+	sub, err := fs.Sub(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+	return FromFS(sub), nil
+}
+
+type fsAssetFS struct {
+	fsys fs.FS
+}
+
+// fsName returns an asset name as a name valid for an fs.FS.
+func fsName(name string) string {
+	if name = cleanName(name); name == "" {
+		return "."
+	}
+	return name
+}
+
+func (f *fsAssetFS) Asset(name string) ([]byte, error) {
+	return fs.ReadFile(f.fsys, fsName(name))
+}
+
+func (f *fsAssetFS) AssetHttp(name string) (http.File, error) {
+	return http.FS(f.fsys).Open("/" + fsName(name))
+}
+
+func (f *fsAssetFS) AssetDir(name string) ([]string, error) {
+	entries, err := fs.ReadDir(f.fsys, fsName(name))
+	if err != nil {
+		return nil, err
+	}
+	ret := make([]string, 0, len(entries))
+	for _, e := range entries {
+		ret = append(ret, e.Name())
+	}
+	return ret, nil
+}
+
+// AssetNames returns the names of every file of the FS, sorted.
+func (f *fsAssetFS) AssetNames() []string {
+	var ret []string
+	fs.WalkDir(f.fsys, ".", func(p string, d fs.DirEntry, err error) error {
+		if err == nil && !d.IsDir() {
+			ret = append(ret, p)
+		}
+		return nil
+	})
+	return ret
+}
+
+// AsFS returns the assets as an fs.FS, e.g. for use with fs.WalkDir or
+// template ParseFS. A name is opened from the first AssetFS holding it as a
+// file, and a directory lists the children of the directory in every AssetFS.
+func AsFS(a Assets) fs.FS {
+	return &assetsFS{a}
+}
+
+// AsHttpFileSystem returns the assets as an http.FileSystem, e.g. for use
+// with http.FileServer.
+func AsHttpFileSystem(a Assets) http.FileSystem {
+	return http.FS(AsFS(a))
+}
+
+type assetsFS struct {
+	a Assets
+}
+
+// validPath returns a boolean indicating if a name is valid for an fs.FS,
+// rejecting backslashes an AssetFS would take as separators.
+func validPath(name string) bool {
+	return fs.ValidPath(name) && !strings.Contains(name, "\\")
+}
+
+func (a *assetsFS) Open(name string) (fs.File, error) {
+	if !validPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+	if b, err := a.ReadFile(name); err == nil {
+		return &assetFile{bytes.NewReader(b), assetInfo{path.Base(name), int64(len(b)), false}}, nil
+	}
+	dir := name
+	if dir == "." {
+		dir = ""
+	}
+	seen := make(map[string]bool)
+	var entries []fs.DirEntry
+	found := dir == ""
+	for _, x := range a.a.ListAssetFS() {
+		children, err := x.AssetDir(dir)
+		if err != nil {
+			continue
+		}
+		found = true
+		for _, c := range children {
+			if seen[c] {
+				continue
+			}
+			seen[c] = true
+			p := path.Join(dir, c)
+			_, err := x.AssetDir(p)
+			entries = append(entries, &assetEntry{x, p, err == nil})
+		}
+	}
+	if !found {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Name() < entries[j].Name()
+	})
+	return &assetDir{assetInfo{path.Base(name), 0, true}, entries}, nil
+}
+
+// ReadFile returns the content of the file from the first AssetFS holding it.
+func (a *assetsFS) ReadFile(name string) ([]byte, error) {
+	if !validPath(name) {
+		return nil, &fs.PathError{Op: "read", Path: name, Err: fs.ErrInvalid}
+	}
+	if name != "." {
+		for _, x := range a.a.ListAssetFS() {
+			if b, err := x.Asset(name); err == nil {
+				return b, nil
+			}
+		}
+	}
+	return nil, &fs.PathError{Op: "read", Path: name, Err: fs.ErrNotExist}
+}
+
+// assetInfo is the fs.FileInfo and fs.DirEntry of an asset or a directory of
+// assets.
+type assetInfo struct {
+	name string
+	size int64
+	dir  bool
+}
+
+func (i assetInfo) Name() string { return i.name }
+
+func (i assetInfo) Size() int64 { return i.size }
+
+func (i assetInfo) Mode() fs.FileMode {
+	if i.dir {
+		return fs.ModeDir | 0555
+	}
+	return 0444
+}
+
+func (i assetInfo) ModTime() time.Time { return time.Time{} }
+
+func (i assetInfo) IsDir() bool { return i.dir }
+
+func (i assetInfo) Sys() interface{} { return nil }
+
+func (i assetInfo) Type() fs.FileMode { return i.Mode().Type() }
+
+func (i assetInfo) Info() (fs.FileInfo, error) { return i, nil }
+
+// assetEntry is the fs.DirEntry of a child of a directory of assets, with
+// the size of a file found only when its Info is requested.
+type assetEntry struct {
+	fs   AssetFS
+	path string
+	dir  bool
+}
+
+func (e *assetEntry) Name() string { return path.Base(e.path) }
+
+func (e *assetEntry) IsDir() bool { return e.dir }
+
+func (e *assetEntry) Type() fs.FileMode { return assetInfo{dir: e.dir}.Type() }
+
+func (e *assetEntry) Info() (fs.FileInfo, error) {
+	if e.dir {
+		return assetInfo{e.Name(), 0, true}, nil
+	}
+	size, err := assetSize(e.fs, e.path)
+	if err != nil {
+		return nil, &fs.PathError{Op: "stat", Path: e.path, Err: err}
+	}
+	return assetInfo{e.Name(), size, false}, nil
+}
+
+// assetSize returns the size of an asset from the file info of the AssetFS,
+// reading its content only where there is none.
+func assetSize(x AssetFS, name string) (int64, error) {
+	if f, err := x.AssetHttp(name); err == nil {
+		fi, err := f.Stat()
+		f.Close()
+		if err == nil && !fi.IsDir() {
+			return fi.Size(), nil
+		}
+	}
+	b, err := x.Asset(name)
+	return int64(len(b)), err
+}
+
+type assetFile struct {
+	*bytes.Reader
+	info assetInfo
+}
+
+func (f *assetFile) Stat() (fs.FileInfo, error) { return f.info, nil }
+
+func (f *assetFile) Close() error { return nil }
+
+type assetDir struct {
+	info    assetInfo
+	entries []fs.DirEntry
+}
+
+func (d *assetDir) Stat() (fs.FileInfo, error) { return d.info, nil }
+
+func (d *assetDir) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.info.name, Err: fs.ErrInvalid}
+}
+
+func (d *assetDir) Close() error { return nil }
+
+// ReadDir returns the next n entries of the directory, or every remaining
+// entry where n <= 0.
+func (d *assetDir) ReadDir(n int) ([]fs.DirEntry, error) {
+	if n <= 0 {
+		ret := d.entries
+		d.entries = nil
+		return ret, nil
+	}
+	if len(d.entries) == 0 {
+		return nil, io.EOF
+	}
+	if n > len(d.entries) {
+		n = len(d.entries)
+	}
+	ret := d.entries[:n]
+	d.entries = d.entries[n:]
+	return ret, nil
+}
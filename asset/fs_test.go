@@ -0,0 +1,106 @@
+//go:build go1.16
+// +build go1.16
+
+package asset_test
+
+import (
+	"io/fs"
+	"io/ioutil"
+	"net/http"
+	"reflect"
+	"testing"
+	"testing/fstest"
+
+	"github.com/flxtilla/cxre/asset"
+)
+
+func TestFS(t *testing.T) {
+	embedded := fstest.MapFS{
+		"resources/css/app.css":          {Data: []byte("body {}")},
+		"resources/js/app.js":            {Data: []byte("main()")},
+		"resources/templates/index.html": {Data: []byte("<p>index</p>")},
+	}
+	af, err := asset.SubFS(embedded, "resources")
+	if err != nil {
+		t.Fatalf("SubFS error: %s", err)
+	}
+
+	expected := []string{"css/app.css", "js/app.js", "templates/index.html"}
+	if names := af.AssetNames(); !reflect.DeepEqual(names, expected) {
+		t.Errorf("AssetNames were %v, not %v", names, expected)
+	}
+	if dir, err := af.AssetDir(""); err != nil || !reflect.DeepEqual(dir, []string{"css", "js", "templates"}) {
+		t.Errorf("AssetDir was %v, %v", dir, err)
+	}
+	if b, err := af.Asset("/css/app.css"); err != nil || string(b) != "body {}" {
+		t.Errorf("Asset was %q, %v", b, err)
+	}
+
+	a := asset.New(af, asset.FromFS(fstest.MapFS{
+		"css/app.css":   {Data: []byte("shadowed")},
+		"css/print.css": {Data: []byte("@media print {}")},
+	}))
+	f, err := a.GetAsset("js/app.js")
+	if err != nil {
+		t.Fatalf("GetAsset error: %s", err)
+	}
+	b, _ := ioutil.ReadAll(f)
+	f.Close()
+	if string(b) != "main()" {
+		t.Errorf("GetAsset content was %q", b)
+	}
+
+	fsys := asset.AsFS(a)
+	if err := fstest.TestFS(fsys, "css/app.css", "css/print.css", "js/app.js", "templates/index.html"); err != nil {
+		t.Error(err)
+	}
+	if b, err := ioutil.ReadAll(mustOpen(t, asset.AsHttpFileSystem(a), "/css/app.css")); err != nil || string(b) != "body {}" {
+		t.Errorf("AsHttpFileSystem content was %q, %v", b, err)
+	}
+}
+
+// readingFS is an AssetFS counting the assets read in full.
+type readingFS struct {
+	asset.AssetFS
+	reads int
+}
+
+func (r *readingFS) Asset(name string) ([]byte, error) {
+	b, err := r.AssetFS.Asset(name)
+	if err == nil {
+		r.reads++
+	}
+	return b, err
+}
+
+func TestFSDirSizes(t *testing.T) {
+	r := &readingFS{AssetFS: asset.FromFS(fstest.MapFS{
+		"css/app.css":      {Data: []byte("body {}")},
+		"css/print.css":    {Data: []byte("@media print {}")},
+		"css/vendor/x.css": {Data: []byte("x")},
+	})}
+	entries, err := fs.ReadDir(asset.AsFS(asset.New(r)), "css")
+	if err != nil || len(entries) != 3 {
+		t.Fatalf("ReadDir was %v, %v", entries, err)
+	}
+	if r.reads != 0 {
+		t.Errorf("ReadDir read %d assets", r.reads)
+	}
+	for n, expected := range []int64{7, 15, 0} {
+		fi, err := entries[n].Info()
+		if err != nil || fi.Size() != expected || fi.IsDir() != (expected == 0) {
+			t.Errorf("%s info was %v, %v", entries[n].Name(), fi, err)
+		}
+	}
+	if r.reads != 0 {
+		t.Errorf("entry Info read %d assets", r.reads)
+	}
+}
+
+func mustOpen(t *testing.T, fs http.FileSystem, name string) http.File {
+	f, err := fs.Open(name)
+	if err != nil {
+		t.Fatalf("Open error: %s", err)
+	}
+	return f
+}
@@ -0,0 +1,162 @@
+package asset
+
+import (
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Layer is a named AssetFS of an Assets. Assets are looked for in the layers
+// by decreasing priority, and in the order the layers were set for the same
+// priority.
+type Layer struct {
+	Name     string
+	Priority int
+	AssetFS
+}
+
+// OverlayPriority is the priority of an overlay directory, above any layer
+// of a lower priority.
+const OverlayPriority = 1 << 30
+
+// Layers is implemented by Assets holding named, prioritized layers, and
+// able to report the layer an asset is found in.
+type Layers interface {
+	SetLayer(string, int, AssetFS)
+	RemoveLayer(string) bool
+	ListLayers() []Layer
+	Overlay(string)
+	Resolve(string) (Layer, error)
+}
+
+// SetLayer sets the named layer with a priority, replacing any layer of the
+// same name.
+func (a *assets) SetLayer(name string, priority int, af AssetFS) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.set(name, priority, af)
+}
+
+func (a *assets) set(name string, priority int, af AssetFS) {
+	a.remove(name)
+	a.added++
+	a.a = append(a.a, layer{Layer{name, priority, af}, a.added})
+	sort.SliceStable(a.a, func(i, j int) bool {
+		if a.a[i].Priority != a.a[j].Priority {
+			return a.a[i].Priority > a.a[j].Priority
+		}
+		return a.a[i].order < a.a[j].order
+	})
+}
+
+func (a *assets) remove(name string) bool {
+	for i, l := range a.a {
+		if l.Name == name {
+			a.a = append(a.a[:i], a.a[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// RemoveLayer removes the named layer, returning a boolean indicating if
+// there was one.
+func (a *assets) RemoveLayer(name string) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.remove(name)
+}
+
+// ListLayers returns the layers in the order assets are looked for in them.
+func (a *assets) ListLayers() []Layer {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	ret := make([]Layer, 0, len(a.a))
+	for _, l := range a.a {
+		ret = append(ret, l.Layer)
+	}
+	return ret
+}
+
+// Overlay sets a layer of a directory on disk, named by the directory, at
+// OverlayPriority, shadowing the assets of any other layer with files read
+// from disk on every request, e.g. for editing packed assets in development.
+func (a *assets) Overlay(dir string) {
+	a.SetLayer("overlay:"+dir, OverlayPriority, DirFS(dir))
+}
+
+// Resolve returns the layer GetAsset finds the named asset in.
+func (a *assets) Resolve(requested string) (Layer, error) {
+	for _, l := range a.ListLayers() {
+		if f, err := l.AssetHttp(requested); err == nil {
+			f.Close()
+			return l, nil
+		}
+	}
+	return Layer{}, assetUnavailable(requested)
+}
+
+// layer is a Layer of an assets, with the order it was set in.
+type layer struct {
+	Layer
+	order int
+}
+
+// cleanName returns an asset name as a slash separated path relative to the
+// root of an AssetFS, being empty for the root.
+func cleanName(name string) string {
+	return strings.Trim(path.Clean("/"+strings.Replace(name, "\\", "/", -1)), "/")
+}
+
+// DirFS returns an AssetFS of the files of a directory on disk, read on every
+// request.
+func DirFS(dir string) AssetFS {
+	return &dirFS{dir}
+}
+
+type dirFS struct {
+	dir string
+}
+
+func (d *dirFS) path(name string) string {
+	return filepath.Join(d.dir, filepath.FromSlash(cleanName(name)))
+}
+
+func (d *dirFS) Asset(name string) ([]byte, error) {
+	return ioutil.ReadFile(d.path(name))
+}
+
+func (d *dirFS) AssetHttp(name string) (http.File, error) {
+	return http.Dir(d.dir).Open("/" + cleanName(name))
+}
+
+func (d *dirFS) AssetDir(name string) ([]string, error) {
+	fis, err := ioutil.ReadDir(d.path(name))
+	if err != nil {
+		return nil, err
+	}
+	ret := make([]string, 0, len(fis))
+	for _, fi := range fis {
+		ret = append(ret, fi.Name())
+	}
+	return ret, nil
+}
+
+// AssetNames returns the names of every file beneath the directory, sorted.
+func (d *dirFS) AssetNames() []string {
+	var ret []string
+	filepath.Walk(d.dir, func(p string, fi os.FileInfo, err error) error {
+		if err != nil || !fi.Mode().IsRegular() {
+			return nil
+		}
+		if rel, err := filepath.Rel(d.dir, p); err == nil {
+			ret = append(ret, filepath.ToSlash(rel))
+		}
+		return nil
+	})
+	return ret
+}
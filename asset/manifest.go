@@ -0,0 +1,82 @@
+package asset
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"path"
+	"sort"
+	"strings"
+)
+
+// FingerprintLength is the number of hex digits of a content hash in a
+// fingerprinted name.
+const FingerprintLength = 12
+
+// FingerprintName returns the name of a file with a hash of its content
+// before the extension, e.g. css/app.3f2a9c1b0d4e.css for css/app.css. A
+// name without an extension is returned unchanged.
+func FingerprintName(name string, content []byte) string {
+	ext := path.Ext(name)
+	stem := strings.TrimSuffix(name, ext)
+	if ext == "" || path.Base(stem) == "" || strings.HasSuffix(stem, "/") {
+		return name
+	}
+	sum := sha1.Sum(content)
+	return stem + "." + hex.EncodeToString(sum[:])[:FingerprintLength] + ext
+}
+
+// Manifest maps the slash separated names of files to their fingerprinted
+// names.
+type Manifest map[string]string
+
+// Add adds the fingerprinted name of a file with the content, returning it.
+func (m Manifest) Add(name string, content []byte) string {
+	fp := FingerprintName(name, content)
+	m[name] = fp
+	return fp
+}
+
+// Original returns a map of fingerprinted names to the names of the files.
+func (m Manifest) Original() map[string]string {
+	ret := make(map[string]string, len(m))
+	for name, fp := range m {
+		if fp != name {
+			ret[fp] = name
+		}
+	}
+	return ret
+}
+
+// Names returns the names of the files of the manifest, sorted.
+func (m Manifest) Names() []string {
+	ret := make([]string, 0, len(m))
+	for name := range m {
+		ret = append(ret, name)
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+// WriteTo writes the manifest as a JSON object sorted by name.
+func (m Manifest) WriteTo(w io.Writer) (int64, error) {
+	b, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return 0, err
+	}
+	n, err := w.Write(append(b, '\n'))
+	return int64(n), err
+}
+
+var manifestUnreadable = Xrror("Manifest %s unreadable: %s").Out
+
+// ReadManifest reads a manifest written by Manifest WriteTo, e.g. by the pack
+// tool, naming it in any error.
+func ReadManifest(r io.Reader, name string) (Manifest, error) {
+	m := make(Manifest)
+	if err := json.NewDecoder(r).Decode(&m); err != nil {
+		return nil, manifestUnreadable(name, err)
+	}
+	return m, nil
+}
@@ -13,21 +13,23 @@ type InputConfig struct {
 }
 
 type Config struct {
-	Package     string
-	Tags        string
-	Input       []InputConfig
-	Output      string
-	FSPrefix    string
-	FSName      string
-	StripPrefix string
-	NoMemCopy   bool
-	NoCompress  bool
-	Debug       bool
-	Dev         bool
-	NoMetadata  bool
-	Mode        uint
-	ModTime     int64
-	Ignore      []*regexp.Regexp
+	Package      string
+	Tags         string
+	Input        []InputConfig
+	Output       string
+	FSPrefix     string
+	FSName       string
+	StripPrefix  string
+	NoMemCopy    bool
+	NoCompress   bool
+	Debug        bool
+	Dev          bool
+	NoMetadata   bool
+	Mode         uint
+	ModTime      int64
+	Ignore       []*regexp.Regexp
+	Manifest     string
+	Reproducible bool
 }
 
 func NewConfig() *Config {
@@ -48,6 +50,10 @@ func (c *Config) validate() error {
 		return fmt.Errorf("Missing package name")
 	}
 
+	if c.Reproducible && c.Debug {
+		return fmt.Errorf("Debug output embeds absolute paths and is not reproducible, use Dev.")
+	}
+
 	for _, input := range c.Input {
 		_, err := os.Lstat(input.Path)
 		if err != nil {
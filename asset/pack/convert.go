@@ -30,6 +30,16 @@ func Translate(c *Config) error {
 		}
 	}
 
+	// Sort the assets for reproducible output, if applicable.
+	sortAssets(c, toc)
+
+	// Write the manifest of fingerprinted names, if applicable.
+	if len(c.Manifest) > 0 {
+		if err = writeManifest(c, toc); err != nil {
+			return err
+		}
+	}
+
 	// Create output file.
 	fd, err := os.Create(c.Output)
 	if err != nil {
@@ -57,6 +67,9 @@ func Translate(c *Config) error {
 
 	for _, asset := range toc {
 		relative, _ := filepath.Rel(wd, asset.Path)
+		if c.Reproducible {
+			relative = asset.Name
+		}
 		if _, err = fmt.Fprintf(bfd, "// %s\n", filepath.ToSlash(relative)); err != nil {
 			return err
 		}
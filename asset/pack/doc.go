@@ -90,6 +90,23 @@ Running with the `-prefix` flag, we get:
 	$ pack -stripPrefix "/path/to/" /path/to/templates/
 	_bindata["templates/foo.html"] = templates_foo_html
 
+Fingerprint manifest
+With the optional Manifest field, or the `-manifest` flag, pack also writes a
+JSON manifest of the asset names to names fingerprinted with a hash of their
+content, e.g. css/app.css to css/app.3f2a9c1b0d4e.css, as read by
+asset.ReadManifest. Naming the manifest in the static_manifest setting serves
+the assets at those names without hashing them at startup.
+
+Reproducible output
+The Reproducible option, or the `-reproducible` flag, makes identical inputs
+produce identical output regardless of input order, file modes, modification
+times or working directory. Assets are sorted by name, listed by name in the
+header, given the Mode option or mode 0644, given the ModTime option or the
+time of the SOURCE_DATE_EPOCH environment variable, and compressed at a
+fixed level with an empty gzip header. The pack tool is reproducible by
+default where SOURCE_DATE_EPOCH is set. Debug output embeds absolute paths
+and is never reproducible; use Dev instead.
+
 Build tags
 With the optional Tags field, you can specify any go build tags that
 must be fulfilled for the output file to be included in a build. This
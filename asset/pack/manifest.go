@@ -0,0 +1,30 @@
+package pack
+
+import (
+	"io/ioutil"
+	"os"
+
+	"github.com/flxtilla/cxre/asset"
+)
+
+// writeManifest writes the fingerprinted names of the assets, as read by
+// asset.ReadManifest, to the file named by the Manifest option.
+func writeManifest(c *Config, toc []Asset) error {
+	m := make(asset.Manifest)
+	for _, a := range toc {
+		b, err := ioutil.ReadFile(a.Path)
+		if err != nil {
+			return err
+		}
+		m.Add(a.Name, b)
+	}
+
+	fd, err := os.Create(c.Manifest)
+	if err != nil {
+		return err
+	}
+	defer fd.Close()
+
+	_, err = m.WriteTo(fd)
+	return err
+}
@@ -53,6 +53,9 @@ func parseArgs() *pack.Config {
 	flag.UintVar(&c.Mode, "mode", c.Mode, "Optional file mode override for all files.")
 	flag.Int64Var(&c.ModTime, "modtime", c.ModTime, "Optional modification unix timestamp override for all files.")
 	flag.StringVar(&c.Output, "o", c.Output, "Optional name of the output file to be generated.")
+	_, c.Reproducible = pack.SourceDateEpoch()
+	flag.BoolVar(&c.Reproducible, "reproducible", c.Reproducible, "Sort assets and normalize modes, modification times and compression so identical inputs produce identical output. The default where SOURCE_DATE_EPOCH is set.")
+	flag.StringVar(&c.Manifest, "manifest", c.Manifest, "Optional name of a JSON manifest of fingerprinted asset names to be generated.")
 	flag.BoolVar(&version, "version", false, "Displays version information.")
 
 	ignore := make([]string, 0)
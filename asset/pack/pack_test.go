@@ -0,0 +1,117 @@
+package pack
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeInputs(t *testing.T, dir string, mod time.Time, mode os.FileMode) {
+	for name, content := range map[string]string{
+		"css/app.css":    "body {}",
+		"js/app.js":      "main()",
+		"templates.html": "<p>index</p>",
+	} {
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(p, []byte(content), mode); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chmod(p, mode); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(p, mod, mod); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func translate(t *testing.T, dir string, reproducible bool) []byte {
+	c := NewConfig()
+	c.Input = []InputConfig{{Path: filepath.Join(dir, "in"), Recursive: true}}
+	c.StripPrefix = filepath.Join(dir, "in") + string(filepath.Separator)
+	c.Output = filepath.Join(dir, "packed.go")
+	c.Reproducible = reproducible
+	if err := Translate(c); err != nil {
+		t.Fatalf("Translate error: %s", err)
+	}
+	b, err := ioutil.ReadFile(c.Output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestReproducible(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pack")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	os.Unsetenv("SOURCE_DATE_EPOCH")
+	in := filepath.Join(dir, "in")
+
+	writeInputs(t, in, time.Unix(1000000000, 0), 0644)
+	first, variable := translate(t, dir, true), translate(t, dir, false)
+	writeInputs(t, in, time.Unix(1500000000, 0), 0600)
+	if second := translate(t, dir, true); !bytes.Equal(first, second) {
+		t.Errorf("reproducible output changed with the modification times and modes of its inputs:\n%s\n%s", first, second)
+	}
+	if changed := translate(t, dir, false); bytes.Equal(variable, changed) {
+		t.Error("output without Reproducible did not change with the modification times of its inputs")
+	}
+	if bytes.Contains(first, []byte(dir)) {
+		t.Errorf("reproducible output contains the input directory %s", dir)
+	}
+	if !strings.Contains(string(first), "mode: os.FileMode(420), modTime: time.Unix(0, 0)") {
+		t.Errorf("reproducible output did not have ReproducibleMode and the unix epoch:\n%s", first)
+	}
+}
+
+func TestReproducibleMetadata(t *testing.T) {
+	f, err := ioutil.TempFile("", "pack")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	defer os.Remove(f.Name())
+	mod := time.Unix(1000000000, 0)
+	os.Chmod(f.Name(), 0600)
+	os.Chtimes(f.Name(), mod, mod)
+	fi, err := os.Stat(f.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Unsetenv("SOURCE_DATE_EPOCH")
+
+	for _, c := range []struct {
+		epoch        string
+		reproducible bool
+		mode         uint
+		modTime      int64
+		expectedMode uint
+		expectedTime int64
+	}{
+		{"", false, 0, 0, 0600, 1000000000},
+		{"", true, 0, 0, ReproducibleMode, 0},
+		{"1500000000", true, 0, 0, ReproducibleMode, 1500000000},
+		{" 1500000000\n", true, 0, 0, ReproducibleMode, 1500000000},
+		{"-1", true, 0, 0, ReproducibleMode, 0},
+		{"yesterday", true, 0, 0, ReproducibleMode, 0},
+		{"1500000000", false, 0, 0, 0600, 1000000000},
+		{"1500000000", true, 0755, 42, 0755, 42},
+		{"", false, 01755, 42, 0755, 42},
+	} {
+		os.Setenv("SOURCE_DATE_EPOCH", c.epoch)
+		cfg := &Config{Reproducible: c.reproducible, Mode: c.mode, ModTime: c.modTime}
+		if mode, modTime, size := metadata(cfg, fi); mode != c.expectedMode || modTime != c.expectedTime || size != 0 {
+			t.Errorf("metadata with SOURCE_DATE_EPOCH %q, %+v was %o, %d, %d", c.epoch, cfg, mode, modTime, size)
+		}
+	}
+}
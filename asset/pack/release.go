@@ -2,7 +2,6 @@ package pack
 
 import (
 	"bytes"
-	"compress/gzip"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -63,9 +62,9 @@ func writeReleaseAsset(w io.Writer, c *Config, asset *Asset) error {
 		}
 	} else {
 		if c.NoMemCopy {
-			err = compressed_nomemcopy(w, asset, fd)
+			err = compressed_nomemcopy(w, c, asset, fd)
 		} else {
-			err = compressed_memcopy(w, asset, fd)
+			err = compressed_memcopy(w, c, asset, fd)
 		}
 	}
 	if err != nil {
@@ -238,13 +237,13 @@ func (fi bindataFileInfo) Sys() interface{} {
 	return err
 }
 
-func compressed_nomemcopy(w io.Writer, asset *Asset, r io.Reader) error {
+func compressed_nomemcopy(w io.Writer, c *Config, asset *Asset, r io.Reader) error {
 	_, err := fmt.Fprintf(w, `var _%s = "`, asset.Func)
 	if err != nil {
 		return err
 	}
 
-	gz := gzip.NewWriter(&StringWriter{Writer: w})
+	gz := compressor(c, &StringWriter{Writer: w})
 	_, err = io.Copy(gz, r)
 	gz.Close()
 
@@ -265,13 +264,13 @@ func %sBytes() ([]byte, error) {
 	return err
 }
 
-func compressed_memcopy(w io.Writer, asset *Asset, r io.Reader) error {
+func compressed_memcopy(w io.Writer, c *Config, asset *Asset, r io.Reader) error {
 	_, err := fmt.Fprintf(w, `var _%s = []byte("`, asset.Func)
 	if err != nil {
 		return err
 	}
 
-	gz := gzip.NewWriter(&StringWriter{Writer: w})
+	gz := compressor(c, &StringWriter{Writer: w})
 	_, err = io.Copy(gz, r)
 	gz.Close()
 
@@ -348,20 +347,7 @@ func asset_release_common(w io.Writer, c *Config, asset *Asset) error {
 		return err
 	}
 
-	mode := uint(fi.Mode())
-	modTime := fi.ModTime().Unix()
-	size := fi.Size()
-	if c.NoMetadata {
-		mode = 0
-		modTime = 0
-		size = 0
-	}
-	if c.Mode > 0 {
-		mode = uint(os.ModePerm) & c.Mode
-	}
-	if c.ModTime > 0 {
-		modTime = c.ModTime
-	}
+	mode, modTime, size := metadata(c, fi)
 	_, err = fmt.Fprintf(w, `func %s() (*asset, error) {
 	bytes, err := %sBytes()
 	if err != nil {
@@ -0,0 +1,80 @@
+package pack
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ReproducibleMode is the file mode of every asset in reproducible output
+// without a Mode option.
+const ReproducibleMode = 0644
+
+// SourceDateEpoch returns the SOURCE_DATE_EPOCH environment variable, the unix
+// timestamp reproducible builds use for times, and a boolean indicating if
+// it is set to a valid timestamp.
+func SourceDateEpoch() (int64, bool) {
+	v := strings.TrimSpace(os.Getenv("SOURCE_DATE_EPOCH"))
+	if v == "" {
+		return 0, false
+	}
+	epoch, err := strconv.ParseInt(v, 10, 64)
+	if err != nil || epoch < 0 {
+		return 0, false
+	}
+	return epoch, true
+}
+
+// AssetsByName sorts assets by name, the order of reproducible output.
+type AssetsByName []Asset
+
+func (v AssetsByName) Len() int           { return len(v) }
+func (v AssetsByName) Swap(i, j int)      { v[i], v[j] = v[j], v[i] }
+func (v AssetsByName) Less(i, j int) bool { return v[i].Name < v[j].Name }
+
+// sortAssets sorts the assets by name for reproducible output, regardless of
+// the order of the inputs.
+func sortAssets(c *Config, toc []Asset) {
+	if c.Reproducible {
+		sort.Stable(AssetsByName(toc))
+	}
+}
+
+// metadata returns the mode and modification time written for an asset. A
+// reproducible asset has the Mode option or ReproducibleMode, and the ModTime
+// option, SOURCE_DATE_EPOCH, or the unix epoch.
+func metadata(c *Config, fi os.FileInfo) (uint, int64, int64) {
+	mode := uint(fi.Mode())
+	modTime := fi.ModTime().Unix()
+	size := fi.Size()
+	if c.NoMetadata {
+		mode = 0
+		modTime = 0
+		size = 0
+	} else if c.Reproducible {
+		mode = ReproducibleMode
+		modTime, _ = SourceDateEpoch()
+	}
+	if c.Mode > 0 {
+		mode = uint(os.ModePerm) & c.Mode
+	}
+	if c.ModTime > 0 {
+		modTime = c.ModTime
+	}
+	return mode, modTime, size
+}
+
+// compressor returns the gzip writer compressing assets, with an empty header
+// and, for reproducible output, a fixed compression level.
+func compressor(c *Config, w io.Writer) *gzip.Writer {
+	level := gzip.DefaultCompression
+	if c.Reproducible {
+		level = gzip.BestCompression
+	}
+	gz, _ := gzip.NewWriterLevel(w, level)
+	gz.Header = gzip.Header{OS: 255}
+	return gz
+}
@@ -254,7 +254,7 @@ func (b *bindataFS) AssetHttp(requested string) (http.File, error) {
 		f, err := b.open(has)
 		return f, err
 	}
-	return nil, errors.New(fmt.Sprintf("Asset %s unavailable", requested))
+	return nil, errors.New(fmt.Sprintf("Asset %%s unavailable", requested))
 }
 
 func (b *bindataFS) open(name string) (http.File, error) {
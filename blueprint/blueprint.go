@@ -8,10 +8,12 @@ import (
 	"strings"
 
 	"github.com/flxtilla/cxre/engine"
+	"github.com/flxtilla/cxre/flash"
 	"github.com/flxtilla/cxre/route"
 	"github.com/flxtilla/cxre/state"
 	"github.com/flxtilla/cxre/static"
 	"github.com/flxtilla/cxre/status"
+	"github.com/flxtilla/cxre/template"
 )
 
 // Blueprint is an interface for common route bundling in a flotilla app.
@@ -25,9 +27,11 @@ type Blueprint interface {
 	New(string, ...state.Manage) Blueprint
 	Use(...state.Manage)
 	UseAt(int, ...state.Manage)
+	FlashTransport(flash.Transport)
 	Managers() []state.Manage
 	Parent(...Blueprint)
 	Descendents() []Blueprint
+	Templates(template.Templater, ...string)
 	MethodManager
 	status.Statusr
 }
@@ -242,6 +246,20 @@ func (b *blueprint) UseAt(index int, managers ...state.Manage) {
 	b.managers = append(before, after...)
 }
 
+func flashTransport(t flash.Transport) state.Manage {
+	return func(s state.State) {
+		s.SwapTransport(t)
+		s.Receive(s.Request(), s)
+	}
+}
+
+// The default blueprint FlashTransport function sets the flash.Transport used
+// to receive and deliver flashed messages by any route managed by the
+// Blueprint, and must be called before routes are registered.
+func (b *blueprint) FlashTransport(t flash.Transport) {
+	b.UseAt(0, flashTransport(t))
+}
+
 func (b *blueprint) add(r *route.Route) {
 	b.Routes.SetRoute(r)
 }
@@ -383,6 +401,22 @@ func (b *blueprint) STATIC(s static.Static, path string, dirs ...string) {
 	b.push(register, nil)
 }
 
+// The default blueprint Templates function adds template directories for the
+// blueprint when it is registered. Where the Templater is a
+// template.Discoverer, the directories serve the namespace of the blueprint
+// prefix, e.g. admin/users.html for a blueprint at /admin; the directories
+// of a blueprint at the root are added to the template directories.
+func (b *blueprint) Templates(t template.Templater, dirs ...string) {
+	b.push(func() {
+		namespace := strings.Trim(b.prefix, "/")
+		if d, ok := t.(template.Discoverer); ok && namespace != "" {
+			d.TemplateNamespace(namespace, dirs...)
+			return
+		}
+		t.TemplateDirs(dirs...)
+	}, nil)
+}
+
 func formatStatusPath(code, prefix string) string {
 	if prefix == "/" {
 		return fmt.Sprintf("/%s/*filepath", code)
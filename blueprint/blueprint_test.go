@@ -1,14 +1,22 @@
 package blueprint_test
 
 import (
+	"bytes"
 	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"reflect"
 	"testing"
 
 	"github.com/flxtilla/app"
+	"github.com/flxtilla/cxre/asset"
 	"github.com/flxtilla/cxre/blueprint"
+	"github.com/flxtilla/cxre/engine"
 	"github.com/flxtilla/cxre/route"
 	"github.com/flxtilla/cxre/state"
+	"github.com/flxtilla/cxre/store"
+	"github.com/flxtilla/cxre/template"
 	"github.com/flxtilla/cxre/txst"
 )
 
@@ -281,3 +289,69 @@ func TestMountBlueprint(t *testing.T) {
 		mountBlueprint(m, t)
 	}
 }
+
+func TestUrlFor(t *testing.T) {
+	b := blueprint.NewBlueprints("/", func(string, string, engine.Rule) {}, nil)
+	b.Register()
+	b.GET("/users/:id", func(state.State) {})
+	admin := b.New("/admin")
+	admin.Register()
+	admin.Manage(route.New(
+		route.DefaultRouteConf("GET", "/pages/:page", nil),
+		func(rt *route.Route) error { rt.Rename("admin_page"); return nil },
+	))
+	admin.Manage(route.New(route.StaticRouteConf("GET", "/static", nil)))
+
+	for _, c := range []struct {
+		name     string
+		params   []interface{}
+		expected string
+	}{
+		{`\users\{p}\get`, []interface{}{1, "extra", "k=v&w"}, "/users/1?value1=extra&k=v%26w"},
+		{"admin_page", []interface{}{map[string]interface{}{"page": "a/b c", "q": 2}}, "/admin/pages/a%2Fb%20c?q=2"},
+	} {
+		if u, err := b.UrlFor(c.name, c.params...); err != nil || u != c.expected {
+			t.Errorf(`UrlFor %s was %s (%v), but should be %s`, c.name, u, err, c.expected)
+		}
+	}
+	if _, err := b.UrlFor("missing", "x"); err == nil || err.Error() != "Unable to get url for route missing with params [x]." {
+		t.Errorf(`UrlFor an unknown route returned %v`, err)
+	}
+	if u, err := b.UrlForStatic("css/site.css"); err != nil || u != "/admin/static/css/site.css" {
+		t.Errorf(`UrlForStatic was %s (%v)`, u, err)
+	}
+}
+
+func TestBlueprintTemplates(t *testing.T) {
+	dir, err := ioutil.TempDir("", "templates")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	appDir, adminDir := filepath.Join(dir, "app"), filepath.Join(dir, "admin")
+	for p, text := range map[string]string{
+		filepath.Join(appDir, "users.html"):   "app users",
+		filepath.Join(adminDir, "users.html"): "admin users",
+	} {
+		os.MkdirAll(filepath.Dir(p), 0755)
+		ioutil.WriteFile(p, []byte(text), 0644)
+	}
+	tr := template.HTMLTemplater(store.New(), asset.New())
+
+	b := blueprint.NewBlueprints("/", func(string, string, engine.Rule) {}, nil)
+	b.Templates(tr, appDir)
+	admin := b.New("/admin")
+	admin.Templates(tr, adminDir)
+	if err := tr.Render(ioutil.Discard, "admin/users.html", nil); err == nil {
+		t.Error("blueprint templates were added before the blueprint was registered")
+	}
+	b.Register()
+	admin.Register()
+
+	for name, expected := range map[string]string{"users.html": "app users", "admin/users.html": "admin users"} {
+		var out bytes.Buffer
+		if err := tr.Render(&out, name, nil); err != nil || out.String() != expected {
+			t.Errorf("%s rendered %q (%v), not %q", name, out.String(), err, expected)
+		}
+	}
+}
@@ -16,6 +16,7 @@ type Blueprints interface {
 	BlueprintExists(string) (Blueprint, bool)
 	Attach(...Blueprint)
 	Mount(string, ...Blueprint) error
+	Urls
 }
 
 type blueprints struct {
@@ -23,11 +24,12 @@ type blueprints struct {
 }
 
 // NewBlueprints returns a default Blueprints provided a string prefix, a
-// HandleFn, and a state.Make function.
+// HandleFn, and a state.Make function. States made for the routes of the
+// Blueprints have the url_for and url_for_static functions.
 func NewBlueprints(prefix string, fn HandleFn, mk state.Make) Blueprints {
-	return &blueprints{
-		Blueprint: newBlueprint(prefix, NewHandles(fn), NewMakes(mk)),
-	}
+	b := &blueprints{}
+	b.Blueprint = newBlueprint(prefix, NewHandles(fn), NewMakes(b.urlMaking(mk)))
+	return b
 }
 
 // ListBlueprints returns an array of attached and/or mounted Blueprint.
@@ -0,0 +1,140 @@
+package blueprint
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+
+	"github.com/flxtilla/cxre/engine"
+	"github.com/flxtilla/cxre/extension"
+	"github.com/flxtilla/cxre/route"
+	"github.com/flxtilla/cxre/state"
+	"github.com/flxtilla/cxre/xrr"
+)
+
+// Urls builds urls for the routes of every blueprint by route name.
+type Urls interface {
+	UrlFor(string, ...interface{}) (string, error)
+	UrlForStatic(string) (string, error)
+	UrlExtension() extension.Extension
+	UrlFunctions() map[string]interface{}
+}
+
+var (
+	UnknownRoute  = xrr.NewXrror("Unable to get url for route %s with params %v.").Out
+	NoStaticRoute = xrr.NewXrror("Unable to get url for static file %s: no static route exists.").Out
+)
+
+// routeNamed returns the route with the name from the first blueprint
+// holding it.
+func (b *blueprints) routeNamed(name string) (*route.Route, bool) {
+	for _, bp := range b.ListBlueprints() {
+		if rt, err := bp.GetRoute(name); err == nil {
+			return rt, true
+		}
+	}
+	return nil, false
+}
+
+// UrlFor returns the url for the route with the name in any blueprint. The
+// parameters are either a single map of values by parameter name, with
+// values not used in the path added to the query string, or values used in
+// order as for route.Route.Url.
+func (b *blueprints) UrlFor(name string, params ...interface{}) (string, error) {
+	rt, ok := b.routeNamed(name)
+	if !ok {
+		return "", UnknownRoute(name, params)
+	}
+	if len(params) == 1 {
+		if named, ok := namedParams(params[0]); ok {
+			u, err := rt.UrlNamed(named)
+			if err != nil {
+				return "", err
+			}
+			return u.String(), nil
+		}
+	}
+	var ps []string
+	for _, p := range params {
+		ps = append(ps, fmt.Sprint(p))
+	}
+	u, err := rt.Url(ps...)
+	if err != nil {
+		return "", err
+	}
+	return u.String(), nil
+}
+
+func namedParams(p interface{}) (map[string]string, bool) {
+	switch m := p.(type) {
+	case map[string]string:
+		return m, true
+	case map[string]interface{}:
+		ret := make(map[string]string, len(m))
+		for k, v := range m {
+			ret[k] = fmt.Sprint(v)
+		}
+		return ret, true
+	}
+	return nil, false
+}
+
+// UrlForStatic returns the url for a file served by a static route, using
+// the first static route of the blueprints in order, by route name within a
+// blueprint.
+func (b *blueprints) UrlForStatic(file string) (string, error) {
+	for _, bp := range b.ListBlueprints() {
+		var static []*route.Route
+		for _, rt := range bp.All() {
+			if rt.Static && rt.Method == http.MethodGet {
+				static = append(static, rt)
+			}
+		}
+		if len(static) == 0 {
+			continue
+		}
+		sort.Slice(static, func(i, j int) bool {
+			return static[i].Name() < static[j].Name()
+		})
+		u, err := static[0].Url(file)
+		if err != nil {
+			return "", err
+		}
+		return u.String(), nil
+	}
+	return "", NoStaticRoute(file)
+}
+
+// UrlExtension returns an extension providing the url_for and url_for_static
+// functions to a state.
+func (b *blueprints) UrlExtension() extension.Extension {
+	return extension.New(
+		"Url_Extension",
+		extension.NewFunction("url_for", func(s state.State, name string, params ...interface{}) (string, error) {
+			return b.UrlFor(name, params...)
+		}),
+		extension.NewFunction("url_for_static", func(s state.State, file string) (string, error) {
+			return b.UrlForStatic(file)
+		}),
+	)
+}
+
+// UrlFunctions returns the url_for and url_for_static functions for use as
+// template functions, e.g. with a Templater AddTemplateFunctions.
+func (b *blueprints) UrlFunctions() map[string]interface{} {
+	return map[string]interface{}{
+		"url_for":        b.UrlFor,
+		"url_for_static": b.UrlForStatic,
+	}
+}
+
+// urlMaking returns a state.Make extending every state made by mk with the
+// url functions, of an extension built once for every state.
+func (b *blueprints) urlMaking(mk state.Make) state.Make {
+	ext := b.UrlExtension()
+	return func(rw http.ResponseWriter, rq *http.Request, rs *engine.Result, m []state.Manage) state.State {
+		s := mk(rw, rq, rs, m)
+		s.Extend(ext)
+		return s
+	}
+}
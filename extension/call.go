@@ -35,6 +35,12 @@ func call(fn reflect.Value, args ...interface{}) (interface{}, error) {
 		argv[i] = value
 	}
 	result := fn.Call(argv)
+	if len(result) == 1 && typ.Out(0) == rferrorType {
+		if !result[0].IsNil() {
+			return nil, result[0].Interface().(error)
+		}
+		return nil, nil
+	}
 	if len(result) == 2 && !result[1].IsNil() {
 		return result[0].Interface(), result[1].Interface().(error)
 	}
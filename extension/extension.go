@@ -60,8 +60,20 @@ func (e *extension) MustCall(fnName string, arg ...interface{}) interface{} {
 
 func (e *extension) SetExtensionFunctions(fns ...Function) {
 	for _, fn := range fns {
+		if _, exists := e.extensions[fn.Key()]; exists {
+			e.replaceFunction(fn)
+		} else {
+			e.functions = append(e.functions, fn)
+		}
 		e.extensions[fn.Key()] = valueFunc(fn.Value())
-		e.functions = append(e.functions, fn)
+	}
+}
+
+func (e *extension) replaceFunction(fn Function) {
+	for i, f := range e.functions {
+		if f.Key() == fn.Key() {
+			e.functions[i] = fn
+		}
 	}
 }
 
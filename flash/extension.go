@@ -0,0 +1,74 @@
+package flash
+
+import "github.com/flxtilla/cxre/extension"
+
+func mkFunction(k string, v interface{}) extension.Function {
+	return extension.NewFunction(k, v)
+}
+
+var flashFns = []extension.Function{
+	mkFunction("flash", flash),
+	mkFunction("flash_envelope", flashEnvelope),
+	mkFunction("flash_message", flashMessage),
+	mkFunction("flash_messages", flashMessages),
+	mkFunction("flash_messages_all", flashMessagesAll),
+	mkFunction("flash_now", flashNow),
+	mkFunction("flashes", flashes),
+	mkFunction("flashes_all", flashesAll),
+	mkFunction("keep_flashes", keepFlashes),
+	mkFunction("peek_flashes", peekFlashes),
+	mkFunction("peek_flashes_all", peekFlashesAll),
+}
+
+// FlashExtension provides flash functions to any extension.Extension where
+// the first inserted value is a Flasher, e.g. a flotilla state. Templates
+// may access structured messages with {{ .Call "flash_messages" "category" }}.
+var FlashExtension extension.Extension = extension.New("Flash_Extension", flashFns...)
+
+func flash(f Flasher, category, text string) error {
+	f.Flash(category, text)
+	return nil
+}
+
+func flashEnvelope(f Flasher, data interface{}) map[string]interface{} {
+	return Envelope(f, data)
+}
+
+func flashMessage(f Flasher, category string, level string, text string, data ...map[string]interface{}) error {
+	f.FlashMessage(NewMessage(category, Level(level), text, data...))
+	return nil
+}
+
+func flashMessages(f Flasher, category string) Messages {
+	return f.Messages(category)
+}
+
+func flashMessagesAll(f Flasher) Messages {
+	return f.MessagesAll()
+}
+
+func flashes(f Flasher, category string) []string {
+	return f.Flashes(category)
+}
+
+func flashesAll(f Flasher) Flashes {
+	return f.FlashesAll()
+}
+
+func flashNow(f Flasher, category, text string) error {
+	f.Now(category, text)
+	return nil
+}
+
+func keepFlashes(f Flasher, categories ...string) error {
+	f.Keep(categories...)
+	return nil
+}
+
+func peekFlashes(f Flasher, category string) Messages {
+	return f.Peek(category)
+}
+
+func peekFlashesAll(f Flasher) Messages {
+	return f.PeekAll()
+}
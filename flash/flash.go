@@ -1,66 +1,351 @@
 package flash
 
 import (
+	"encoding/gob"
+	"net/http"
+	"sort"
+
 	"github.com/flxtilla/cxre/session"
 )
 
+func init() {
+	gob.Register(Flashes{})
+	gob.Register(Messages{})
+}
+
+// Flashes is the legacy representation of flashed messages, a map of category
+// to string messages.
 type Flashes map[string][]string
 
+// Level indicates the severity of a flashed Message.
+type Level string
+
+const (
+	Debug   Level = "debug"
+	Info    Level = "info"
+	Success Level = "success"
+	Warning Level = "warning"
+	Error   Level = "error"
+)
+
+// Message is a single structured flash message.
+type Message struct {
+	Category string                 `json:"category"`
+	Level    Level                  `json:"level"`
+	Text     string                 `json:"text"`
+	Data     map[string]interface{} `json:"data,omitempty"`
+}
+
+// NewMessage returns a Message with the provided category, level, text, and
+// optional data maps merged in order.
+func NewMessage(category string, level Level, text string, data ...map[string]interface{}) Message {
+	m := Message{
+		Category: category,
+		Level:    level,
+		Text:     text,
+	}
+	for _, d := range data {
+		for k, v := range d {
+			if m.Data == nil {
+				m.Data = make(map[string]interface{})
+			}
+			m.Data[k] = v
+		}
+	}
+	return m
+}
+
+// String returns the Message text.
+func (m Message) String() string {
+	return m.Text
+}
+
+// Get returns the value for key from the Message data, or nil.
+func (m Message) Get(key string) interface{} {
+	if m.Data == nil {
+		return nil
+	}
+	return m.Data[key]
+}
+
+// Messages is an ordered list of Message.
+type Messages []Message
+
+// Category returns Messages in the provided category.
+func (ms Messages) Category(category string) Messages {
+	var ret Messages
+	for _, m := range ms {
+		if m.Category == category {
+			ret = append(ret, m)
+		}
+	}
+	return ret
+}
+
+// Level returns Messages at the provided level.
+func (ms Messages) Level(level Level) Messages {
+	var ret Messages
+	for _, m := range ms {
+		if m.Level == level {
+			ret = append(ret, m)
+		}
+	}
+	return ret
+}
+
+// Strings returns the text of each message.
+func (ms Messages) Strings() []string {
+	var ret []string
+	for _, m := range ms {
+		ret = append(ret, m.Text)
+	}
+	return ret
+}
+
+// Flashes returns Messages in the legacy Flashes format.
+func (ms Messages) Flashes() Flashes {
+	ret := make(Flashes)
+	for _, m := range ms {
+		ret[m.Category] = append(ret[m.Category], m.Text)
+	}
+	return ret
+}
+
+func fromFlashes(fs Flashes) Messages {
+	var keys []string
+	for k := range fs {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var ret Messages
+	for _, k := range keys {
+		for _, text := range fs[k] {
+			ret = append(ret, NewMessage(k, Info, text))
+		}
+	}
+	return ret
+}
+
+// Flasher manages flashed messages across requests. Messages read with Peek
+// remain available, while messages read with Consume (or Flashes, Messages,
+// and their All variants) are not carried to the next request unless kept
+// with Keep. Messages flashed with Now are available to the current request
+// only.
 type Flasher interface {
 	Flashes(string) []string
 	FlashesAll() Flashes
+	Messages(string) Messages
+	MessagesAll() Messages
+	Peek(string) Messages
+	PeekAll() Messages
+	Consume(string) Messages
+	ConsumeAll() Messages
+	Keep(...string)
+	Now(string, string)
+	NowMessage(Message)
 	In(session.SessionStore) bool
 	Out(session.SessionStore) bool
+	SwapTransport(Transport)
+	Receive(*http.Request, session.SessionStore) bool
+	Deliver(http.ResponseWriter, *http.Request, session.SessionStore) bool
 	Flash(string, string)
+	FlashMessage(Message)
+	FlashDebug(string, string, ...map[string]interface{})
+	FlashInfo(string, string, ...map[string]interface{})
+	FlashSuccess(string, string, ...map[string]interface{})
+	FlashWarning(string, string, ...map[string]interface{})
+	FlashError(string, string, ...map[string]interface{})
 }
 
 func New() Flasher {
-	return &flasher{}
+	return &flasher{transport: SessionTransport}
+}
+
+type held struct {
+	Message
+	received, consumed, kept, now bool
 }
 
 type flasher struct {
-	readOnce bool
-	flashes  Flashes
+	held      []*held
+	transport Transport
 }
 
+const flashKey = "_flashes"
+
 func (f *flasher) Flashes(key string) []string {
-	if ret, ok := f.flashes[key]; ok {
-		f.readOnce = true
-		return ret
-	}
-	return nil
+	return f.Consume(key).Strings()
 }
 
 func (f *flasher) FlashesAll() Flashes {
-	ret := make(Flashes)
-	for k, v := range f.flashes {
-		ret[k] = v
+	return f.ConsumeAll().Flashes()
+}
+
+func (f *flasher) Messages(key string) Messages {
+	return f.Consume(key)
+}
+
+func (f *flasher) MessagesAll() Messages {
+	return f.ConsumeAll()
+}
+
+func (f *flasher) read(match func(*held) bool, consume bool) Messages {
+	var ret Messages
+	for _, h := range f.held {
+		if !h.consumed && match(h) {
+			ret = append(ret, h.Message)
+			if consume {
+				h.consumed = true
+			}
+		}
 	}
-	f.readOnce = true
-	f.flashes = nil
 	return ret
 }
 
-func (f *flasher) In(s session.SessionStore) bool {
-	if in := s.Get("_flashes"); in != nil {
-		if inf, ok := in.(Flashes); ok {
-			f.flashes = inf
-			return true
+func inCategory(category string) func(*held) bool {
+	return func(h *held) bool { return h.Category == category }
+}
+
+func everyCategory(*held) bool { return true }
+
+// Peek returns unconsumed messages for the category without consuming them.
+func (f *flasher) Peek(key string) Messages {
+	return f.read(inCategory(key), false)
+}
+
+// PeekAll returns all unconsumed messages without consuming them.
+func (f *flasher) PeekAll() Messages {
+	return f.read(everyCategory, false)
+}
+
+// Consume returns unconsumed messages for the category, consuming them.
+func (f *flasher) Consume(key string) Messages {
+	return f.read(inCategory(key), true)
+}
+
+// ConsumeAll returns all unconsumed messages, consuming them.
+func (f *flasher) ConsumeAll() Messages {
+	return f.read(everyCategory, true)
+}
+
+// Keep carries messages in the provided categories, or all messages if no
+// category is provided, to the next request whether consumed or not.
+func (f *flasher) Keep(keys ...string) {
+	for _, h := range f.held {
+		if len(keys) == 0 {
+			h.kept = true
+			continue
+		}
+		for _, k := range keys {
+			if h.Category == k {
+				h.kept = true
+			}
 		}
 	}
-	return false
 }
 
+func (f *flasher) persisted() Messages {
+	var ret Messages
+	for _, h := range f.held {
+		if h.kept || (!h.consumed && !h.now) {
+			ret = append(ret, h.Message)
+		}
+	}
+	return ret
+}
+
+func (f *flasher) receive(in Messages) bool {
+	var flashed []*held
+	for _, h := range f.held {
+		if !h.received {
+			flashed = append(flashed, h)
+		}
+	}
+	f.held = flashed
+	for _, m := range in {
+		f.held = append(f.held, &held{Message: m, received: true})
+	}
+	return in != nil
+}
+
+// In reads messages stored in the session, replacing any messages previously
+// read by the Flasher.
+func (f *flasher) In(s session.SessionStore) bool {
+	return f.receive(SessionTransport.Read(nil, s))
+}
+
+// Out stores messages that are unconsumed or kept in the session, returning
+// a boolean indicating if any messages were stored.
 func (f *flasher) Out(s session.SessionStore) bool {
-	if err := s.Set("_flashes", f.flashes); err != nil {
-		return true
+	out := f.persisted()
+	if err := SessionTransport.Write(nil, nil, s, out); err != nil {
+		return false
 	}
-	return false
+	return len(out) > 0
+}
+
+// SwapTransport sets the Transport used by Receive and Deliver.
+func (f *flasher) SwapTransport(t Transport) {
+	f.transport = t
+}
+
+// Receive reads messages with the Flasher Transport, replacing any messages
+// previously read by the Flasher.
+func (f *flasher) Receive(rq *http.Request, s session.SessionStore) bool {
+	return f.receive(f.transport.Read(rq, s))
+}
+
+// Deliver writes messages that are unconsumed or kept with the Flasher
+// Transport, returning a boolean indicating if any messages were written.
+func (f *flasher) Deliver(rw http.ResponseWriter, rq *http.Request, s session.SessionStore) bool {
+	out := f.persisted()
+	if err := f.transport.Write(rw, rq, s, out); err != nil {
+		return false
+	}
+	return len(out) > 0
 }
 
 func (f *flasher) Flash(key, value string) {
-	if f.flashes == nil {
-		f.flashes = make(Flashes)
+	f.FlashMessage(NewMessage(key, Info, value))
+}
+
+func (f *flasher) add(m Message, now bool) {
+	if m.Level == "" {
+		m.Level = Info
 	}
-	f.flashes[key] = append(f.flashes[key], value)
+	f.held = append(f.held, &held{Message: m, now: now})
+}
+
+func (f *flasher) FlashMessage(m Message) {
+	f.add(m, false)
+}
+
+// Now flashes a message available to the current request only.
+func (f *flasher) Now(key, value string) {
+	f.NowMessage(NewMessage(key, Info, value))
+}
+
+// NowMessage flashes a Message available to the current request only.
+func (f *flasher) NowMessage(m Message) {
+	f.add(m, true)
+}
+
+func (f *flasher) FlashDebug(key, value string, data ...map[string]interface{}) {
+	f.FlashMessage(NewMessage(key, Debug, value, data...))
+}
+
+func (f *flasher) FlashInfo(key, value string, data ...map[string]interface{}) {
+	f.FlashMessage(NewMessage(key, Info, value, data...))
+}
+
+func (f *flasher) FlashSuccess(key, value string, data ...map[string]interface{}) {
+	f.FlashMessage(NewMessage(key, Success, value, data...))
+}
+
+func (f *flasher) FlashWarning(key, value string, data ...map[string]interface{}) {
+	f.FlashMessage(NewMessage(key, Warning, value, data...))
+}
+
+func (f *flasher) FlashError(key, value string, data ...map[string]interface{}) {
+	f.FlashMessage(NewMessage(key, Error, value, data...))
 }
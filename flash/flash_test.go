@@ -1,9 +1,14 @@
-package flash
+package flash_test
 
 import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
 
 	"github.com/flxtilla/app"
+	"github.com/flxtilla/cxre/flash"
+	"github.com/flxtilla/cxre/session"
 	"github.com/flxtilla/cxre/state"
 	"github.com/flxtilla/cxre/txst"
 )
@@ -26,7 +31,7 @@ func TestFlash(t *testing.T) {
 		"/ft1/",
 		func(t *testing.T) state.Manage {
 			return func(s state.State) {
-				//c.Call("flash", "testing", "test flash message")
+				s.Call("flash", "testing", "test flash message")
 			}
 		},
 	)
@@ -36,13 +41,12 @@ func TestFlash(t *testing.T) {
 		"/ft2/",
 		func(t *testing.T) state.Manage {
 			return func(s state.State) {
-				//fl := Flshr(c)
-				//v := fl.Write("testing")
-				//if !strings.Contains(v[0], "test flash message") {
-				//	t.Errorf(`flash messaging expected "test flash message" as first message, but it was %s`, v[0])
-				//}
-				//fl.WriteAll()
-				//c.Call("flash", "testing_two", "second test flash message")
+				v := s.Flashes("testing")
+				if len(v) == 0 || !strings.Contains(v[0], "test flash message") {
+					t.Errorf(`flash messaging expected "test flash message" as first message, but it was %v`, v)
+				}
+				s.FlashesAll()
+				s.Call("flash", "testing_two", "second test flash message")
 			}
 		},
 	)
@@ -52,23 +56,212 @@ func TestFlash(t *testing.T) {
 		"/ft3/",
 		func(t *testing.T) state.Manage {
 			return func(s state.State) {
-				//fl := Flshr(c)
-				//nv := fl.Write("testing")
-				//if nv != nil {
-				//	t.Errorf(`flasher wrote %s for category "testing", but was expecting a nil value`, nv)
-				//}
-				//v := fl.WriteAll()
-				//expected := v["testing_two"][0]
-				//if !strings.Contains(expected, "second test flash message") {
-				//	t.Errorf(`flash messaging expected "second test flash message" as first message for "testing_two", but it was %s`, expected)
-				//}
-				//v2 := fl.Write("testing_two")
-				//if v2 != nil {
-				//	t.Errorf(`flasher wrote %s for category "testing_two", but was expecting a nil value`, v2)
-				//}
+				nv := s.Flashes("testing")
+				if nv != nil {
+					t.Errorf(`flasher wrote %s for category "testing", but was expecting a nil value`, nv)
+				}
+				v := s.FlashesAll()
+				expected := strings.Join(v["testing_two"], "")
+				if !strings.Contains(expected, "second test flash message") {
+					t.Errorf(`flash messaging expected "second test flash message" as first message for "testing_two", but it was %s`, expected)
+				}
+				v2 := s.Flashes("testing_two")
+				if v2 != nil {
+					t.Errorf(`flasher wrote %s for category "testing_two", but was expecting a nil value`, v2)
+				}
 			}
 		},
 	)
 
 	txst.SessionPerformer(t, a, exp1, exp2, exp3).Perform()
 }
+
+func testSessionStore(t *testing.T) session.SessionStore {
+	config := `{"cookieName":"gosessionid","enableSetCookie":false,"gclifetime":3600,"ProviderConfig":"{\"cookieName\":\"gosessionid\",\"securityKey\":\"flotillacookiehashkey\"}"}`
+	m, err := session.NewManager("cookie", config)
+	if err != nil {
+		t.Fatal("init cookie session err", err)
+	}
+	r, _ := http.NewRequest("GET", "/", nil)
+	ss, err := m.SessionStart(httptest.NewRecorder(), r)
+	if err != nil {
+		t.Fatal("session start err", err)
+	}
+	return ss
+}
+
+func TestMessages(t *testing.T) {
+	ss := testSessionStore(t)
+	ss.Set("_flashes", flash.Flashes{"legacy": []string{"legacy message"}})
+
+	f := flash.New()
+	if !f.In(ss) {
+		t.Fatal("flasher did not read legacy flashes from session")
+	}
+	if v := f.Flashes("legacy"); len(v) != 1 || v[0] != "legacy message" {
+		t.Errorf(`legacy flashes were %v, not [legacy message]`, v)
+	}
+
+	f.FlashError("form", "name is required", map[string]interface{}{"field": "name"})
+	f.FlashSuccess("form", "saved")
+	f.Out(ss)
+
+	g := flash.New()
+	g.In(ss)
+	ms := g.Messages("form")
+	if len(ms) != 2 {
+		t.Fatalf("expected 2 form messages, but there were %d", len(ms))
+	}
+	if ms[0].Level != flash.Error || ms[0].Get("field") != "name" {
+		t.Errorf("first form message was %+v, expected an error for field name", ms[0])
+	}
+	if e := ms.Level(flash.Success); len(e) != 1 || e[0].String() != "saved" {
+		t.Errorf("success form messages were %v, not [saved]", e)
+	}
+
+	b, err := session.EncodeGob(map[interface{}]interface{}{"_flashes": ms})
+	if err != nil {
+		t.Fatal("encoding messages err", err)
+	}
+	dec, err := session.DecodeGob(b)
+	if err != nil {
+		t.Fatal("decoding messages err", err)
+	}
+	if dm, ok := dec["_flashes"].(flash.Messages); !ok || dm[0].Get("field") != "name" {
+		t.Errorf("decoded messages were %+v", dec["_flashes"])
+	}
+}
+
+func TestPeekConsumeKeepNow(t *testing.T) {
+	ss := testSessionStore(t)
+
+	f := flash.New()
+	f.Flash("peeked", "peeked message")
+	f.Flash("consumed", "consumed message")
+	f.Flash("kept", "kept message")
+	f.Now("now", "now message")
+
+	if v := f.Peek("peeked"); len(v) != 1 {
+		t.Errorf("peeked messages were %v, expected 1 message", v)
+	}
+	if v := f.Consume("consumed"); len(v) != 1 {
+		t.Errorf("consumed messages were %v, expected 1 message", v)
+	}
+	if v := f.Consume("consumed"); v != nil {
+		t.Errorf("consumed messages were read twice: %v", v)
+	}
+	f.Consume("kept")
+	f.Keep("kept")
+	if v := f.Flashes("now"); len(v) != 1 || v[0] != "now message" {
+		t.Errorf(`now messages were %v, not [now message]`, v)
+	}
+	if !f.Out(ss) {
+		t.Fatal("flasher did not store messages in session")
+	}
+
+	g := flash.New()
+	g.In(ss)
+	all := g.PeekAll().Flashes()
+	if len(all) != 2 || all["peeked"] == nil || all["kept"] == nil {
+		t.Errorf("next request messages were %v, expected peeked and kept messages only", all)
+	}
+	g.ConsumeAll()
+	if g.Out(ss) {
+		t.Error("flasher stored messages after all were consumed")
+	}
+	if v := ss.Get("_flashes"); v != nil {
+		t.Errorf("session flashes were %v after all were consumed", v)
+	}
+}
+
+func TestHeaderTransport(t *testing.T) {
+	ss := testSessionStore(t)
+	tr := flash.HeaderTransport("", flash.SessionTransport)
+
+	f := flash.New()
+	f.SwapTransport(tr)
+	f.FlashWarning("api", "quota nearly exceeded")
+
+	rq, _ := http.NewRequest("GET", "/api", nil)
+	rq.Header.Set("X-Requested-With", "XMLHttpRequest")
+	w := httptest.NewRecorder()
+	f.Deliver(w, rq, ss)
+	h := w.Header().Get("X-Flash-Messages")
+	if !strings.Contains(h, `"text":"quota nearly exceeded"`) || !strings.Contains(h, `"level":"warning"`) {
+		t.Errorf("flash header was %s", h)
+	}
+	if v := ss.Get("_flashes"); v != nil {
+		t.Errorf("messages delivered by header were stored in session: %v", v)
+	}
+
+	f.Flash("page", "page message")
+	rq, _ = http.NewRequest("GET", "/page", nil)
+	w = httptest.NewRecorder()
+	f.Deliver(w, rq, ss)
+	if h := w.Header().Get("X-Flash-Messages"); h != "" {
+		t.Errorf("flash header was set for a non XHR request: %s", h)
+	}
+	g := flash.New()
+	g.SwapTransport(tr)
+	g.Receive(rq, ss)
+	if v := g.Flashes("page"); len(v) != 1 {
+		t.Errorf("page messages were %v, expected 1 message", v)
+	}
+}
+
+func TestCookieTransport(t *testing.T) {
+	tr := flash.NewCookieTransport("flashes", []byte("cookiesigningkey"))
+
+	f := flash.New()
+	f.SwapTransport(tr)
+	f.FlashSuccess("form", "saved", map[string]interface{}{"id": "1"})
+	w := httptest.NewRecorder()
+	f.Deliver(w, nil, nil)
+
+	rq, _ := http.NewRequest("GET", "/", nil)
+	for _, c := range w.Result().Cookies() {
+		rq.AddCookie(c)
+	}
+	g := flash.New()
+	g.SwapTransport(tr)
+	if !g.Receive(rq, nil) {
+		t.Fatal("flasher did not receive messages from cookie")
+	}
+	ms := g.Messages("form")
+	if len(ms) != 1 || ms[0].Level != flash.Success || ms[0].Get("id") != "1" {
+		t.Errorf("cookie messages were %+v", ms)
+	}
+
+	rq, _ = http.NewRequest("GET", "/", nil)
+	rq.AddCookie(&http.Cookie{Name: "flashes", Value: "W10.tampered"})
+	h := flash.New()
+	h.SwapTransport(tr)
+	if h.Receive(rq, nil) {
+		t.Error("flasher received messages from a tampered cookie")
+	}
+
+	unsigned := &flash.CookieTransport{Name: "flashes"}
+	if err := unsigned.Write(httptest.NewRecorder(), rq, nil, flash.Messages{flash.NewMessage("page", flash.Info, "saved")}); err == nil {
+		t.Error("CookieTransport without a key wrote messages")
+	}
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Error("NewCookieTransport with an empty key did not panic")
+			}
+		}()
+		flash.NewCookieTransport("flashes", nil)
+	}()
+}
+
+func TestEnvelope(t *testing.T) {
+	f := flash.New()
+	f.FlashInfo("api", "created")
+	e := flash.Envelope(f, "data")
+	if ms, ok := e["flashes"].(flash.Messages); !ok || len(ms) != 1 || e["data"] != "data" {
+		t.Errorf("envelope was %v", e)
+	}
+	if v := f.PeekAll(); v != nil {
+		t.Errorf("enveloped messages were not consumed: %v", v)
+	}
+}
@@ -0,0 +1,253 @@
+package flash
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"unicode/utf16"
+
+	"github.com/flxtilla/cxre/session"
+	"github.com/flxtilla/cxre/xrr"
+)
+
+// Transport reads flashed messages from and writes flashed messages to a
+// request, response, and/or session.
+type Transport interface {
+	Read(*http.Request, session.SessionStore) Messages
+	Write(http.ResponseWriter, *http.Request, session.SessionStore, Messages) error
+}
+
+type sessionTransport struct{}
+
+// SessionTransport is the default Transport, storing flashed messages in the
+// session under the "_flashes" key.
+var SessionTransport Transport = &sessionTransport{}
+
+func (t *sessionTransport) Read(rq *http.Request, s session.SessionStore) Messages {
+	if s == nil {
+		return nil
+	}
+	switch v := s.Get(flashKey).(type) {
+	case Messages:
+		return v
+	case Flashes:
+		return fromFlashes(v)
+	case map[string][]string:
+		return fromFlashes(v)
+	}
+	return nil
+}
+
+func (t *sessionTransport) Write(rw http.ResponseWriter, rq *http.Request, s session.SessionStore, ms Messages) error {
+	if s == nil {
+		return nil
+	}
+	if len(ms) == 0 {
+		return s.Delete(flashKey)
+	}
+	return s.Set(flashKey, ms)
+}
+
+// IsXHR returns a boolean indicating if the request was made by XHR or fetch,
+// either with an X-Requested-With header or preferring a JSON response.
+func IsXHR(rq *http.Request) bool {
+	if rq == nil {
+		return false
+	}
+	if strings.EqualFold(rq.Header.Get("X-Requested-With"), "XMLHttpRequest") {
+		return true
+	}
+	accept := rq.Header.Get("Accept")
+	return strings.Contains(accept, "application/json") && !strings.Contains(accept, "text/html")
+}
+
+type headerTransport struct {
+	header   string
+	fallback Transport
+}
+
+// HeaderTransport returns a Transport that writes pending messages as JSON to
+// the named response header for XHR and fetch requests, and otherwise reads
+// and writes messages with the fallback Transport (if not nil), e.g. so that
+// messages flashed before a full page redirect are still delivered.
+func HeaderTransport(header string, fallback Transport) Transport {
+	if header == "" {
+		header = "X-Flash-Messages"
+	}
+	return &headerTransport{header, fallback}
+}
+
+func (t *headerTransport) Read(rq *http.Request, s session.SessionStore) Messages {
+	if t.fallback != nil {
+		return t.fallback.Read(rq, s)
+	}
+	return nil
+}
+
+func (t *headerTransport) Write(rw http.ResponseWriter, rq *http.Request, s session.SessionStore, ms Messages) error {
+	if IsXHR(rq) {
+		if len(ms) > 0 {
+			b, err := asciiJSON(ms)
+			if err != nil {
+				return err
+			}
+			rw.Header().Set(t.header, string(b))
+		}
+		ms = nil
+	}
+	if t.fallback != nil {
+		return t.fallback.Write(rw, rq, s, ms)
+	}
+	return nil
+}
+
+// asciiJSON encodes v as JSON with all non-ASCII characters escaped, so that
+// the result is safe to use as a header value.
+func asciiJSON(v interface{}) ([]byte, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	for _, r := range string(b) {
+		if r < 0x80 {
+			buf.WriteRune(r)
+			continue
+		}
+		for _, u := range utf16.Encode([]rune{r}) {
+			fmt.Fprintf(&buf, `\u%04x`, u)
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+// Envelope returns a JSON serialisable envelope with the provided data and
+// all pending messages, consuming the messages.
+func Envelope(f Flasher, data interface{}) map[string]interface{} {
+	ms := f.ConsumeAll()
+	if ms == nil {
+		ms = Messages{}
+	}
+	return map[string]interface{}{
+		"data":    data,
+		"flashes": ms,
+	}
+}
+
+// CookieTransport stores flashed messages in a signed cookie, for use where
+// server sessions are disabled. Messages are signed but not encrypted, and
+// are limited by the maximum cookie size of the client.
+type CookieTransport struct {
+	Name   string
+	Key    []byte
+	Path   string
+	Secure bool
+}
+
+// NewCookieTransport returns a CookieTransport with the provided cookie name
+// and signing key, panicking where the key is empty.
+func NewCookieTransport(name string, key []byte) *CookieTransport {
+	if len(key) == 0 {
+		panic(MissingFlashKey(name))
+	}
+	return &CookieTransport{
+		Name: name,
+		Key:  key,
+		Path: "/",
+	}
+}
+
+var (
+	InvalidFlashCookie = xrr.NewXrror("flash cookie %s is invalid").Out
+	MissingFlashKey    = xrr.NewXrror("flash cookie %s has no signing key").Out
+)
+
+func (t *CookieTransport) sign(b []byte) []byte {
+	h := hmac.New(sha256.New, t.Key)
+	h.Write(b)
+	return h.Sum(nil)
+}
+
+func (t *CookieTransport) encode(ms Messages) (string, error) {
+	if len(t.Key) == 0 {
+		return "", MissingFlashKey(t.Name)
+	}
+	b, err := json.Marshal(ms)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf(
+		"%s.%s",
+		base64.RawURLEncoding.EncodeToString(b),
+		base64.RawURLEncoding.EncodeToString(t.sign(b)),
+	), nil
+}
+
+func (t *CookieTransport) decode(value string) (Messages, error) {
+	if len(t.Key) == 0 {
+		return nil, MissingFlashKey(t.Name)
+	}
+	parts := strings.SplitN(value, ".", 2)
+	if len(parts) != 2 {
+		return nil, InvalidFlashCookie(t.Name)
+	}
+	b, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, InvalidFlashCookie(t.Name)
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil || !hmac.Equal(sig, t.sign(b)) {
+		return nil, InvalidFlashCookie(t.Name)
+	}
+	var ms Messages
+	if err := json.Unmarshal(b, &ms); err != nil {
+		return nil, err
+	}
+	return ms, nil
+}
+
+func (t *CookieTransport) Read(rq *http.Request, s session.SessionStore) Messages {
+	if rq == nil {
+		return nil
+	}
+	c, err := rq.Cookie(t.Name)
+	if err != nil || c.Value == "" {
+		return nil
+	}
+	ms, err := t.decode(c.Value)
+	if err != nil {
+		return nil
+	}
+	return ms
+}
+
+func (t *CookieTransport) Write(rw http.ResponseWriter, rq *http.Request, s session.SessionStore, ms Messages) error {
+	c := &http.Cookie{
+		Name:     t.Name,
+		Path:     t.Path,
+		HttpOnly: true,
+		Secure:   t.Secure,
+	}
+	if len(ms) == 0 {
+		if rq != nil {
+			if _, err := rq.Cookie(t.Name); err != nil {
+				return nil
+			}
+		}
+		c.MaxAge = -1
+		http.SetCookie(rw, c)
+		return nil
+	}
+	v, err := t.encode(ms)
+	if err != nil {
+		return err
+	}
+	c.Value = v
+	http.SetCookie(rw, c)
+	return nil
+}
@@ -0,0 +1,335 @@
+package i18n
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/flxtilla/cxre/xrr"
+)
+
+var (
+	CatalogueParseError = xrr.NewXrror("Unable to parse catalogue %s at line %d.").Out
+	UnknownCatalogue    = xrr.NewXrror("Catalogue %s is not a .po or .json file.").Out
+)
+
+// catalogue is the translated messages of a locale, keyed by message id, or
+// by context and message id separated by \x04 as in gettext mo files.
+type catalogue struct {
+	locale   string
+	plural   Plural
+	messages map[string][]string
+}
+
+func newCatalogue(locale string) *catalogue {
+	return &catalogue{
+		locale:   locale,
+		plural:   pluralFor(locale),
+		messages: make(map[string][]string),
+	}
+}
+
+// translation returns the form of the message for the count, and a boolean
+// indicating if the catalogue translates the message.
+func (c *catalogue) translation(msgid string, n int) (string, bool) {
+	forms, ok := c.messages[msgid]
+	if !ok {
+		return "", false
+	}
+	i := c.plural(n)
+	if i >= len(forms) || forms[i] == "" {
+		return "", false
+	}
+	return forms[i], true
+}
+
+// merge adds the messages of another catalogue of the same locale, with the
+// plural forms of the other catalogue where it gives its own.
+func (c *catalogue) merge(o *catalogue, pluralForms bool) {
+	for k, v := range o.messages {
+		c.messages[k] = v
+	}
+	if pluralForms {
+		c.plural = o.plural
+	}
+}
+
+// localeFromName returns the locale of a catalogue file, named by the locale
+// alone or after a domain, e.g. fr.po, pt_BR.json or messages.fr.po.
+func localeFromName(name string) string {
+	base := strings.TrimSuffix(path.Base(filepath.ToSlash(name)), path.Ext(name))
+	if i := strings.LastIndex(base, "."); i >= 0 {
+		base = base[i+1:]
+	}
+	return Normalize(base)
+}
+
+func isCatalogue(name string) bool {
+	switch path.Ext(name) {
+	case ".po", ".json":
+		return true
+	}
+	return false
+}
+
+// parseCatalogue parses a PO or JSON catalogue, returning it and a boolean
+// indicating if it gives its own plural forms.
+func parseCatalogue(b []byte, name string) (*catalogue, bool, error) {
+	switch path.Ext(name) {
+	case ".po":
+		return parsePO(b, name)
+	case ".json":
+		return parseJSON(b, name)
+	}
+	return nil, false, UnknownCatalogue(name)
+}
+
+// header applies the Language and Plural-Forms of a PO or JSON header.
+func (c *catalogue) header(language, pluralForms string) (bool, error) {
+	if language != "" {
+		c.locale = Normalize(language)
+		c.plural = pluralFor(c.locale)
+	}
+	if pluralForms == "" {
+		return false, nil
+	}
+	p, err := ParsePluralForms(pluralForms)
+	if err != nil {
+		return false, err
+	}
+	c.plural = p
+	return true, nil
+}
+
+var poHeader = regexp.MustCompile(`(?m)^(Language|Plural-Forms):\s*(.*)$`)
+
+type poEntry struct {
+	ctx, id, plural string
+	strs            map[int]string
+	fuzzy           bool
+	last            func(string)
+}
+
+func parsePO(b []byte, name string) (*catalogue, bool, error) {
+	c := newCatalogue(localeFromName(name))
+	var own bool
+	var err error
+	e := &poEntry{strs: make(map[int]string)}
+	flush := func() {
+		defer func() { e = &poEntry{strs: make(map[int]string)} }()
+		if e.last == nil {
+			return
+		}
+		if e.id == "" && e.ctx == "" {
+			h := make(map[string]string)
+			for _, m := range poHeader.FindAllStringSubmatch(e.strs[0], -1) {
+				h[m[1]] = strings.TrimSpace(m[2])
+			}
+			own, err = c.header(h["Language"], h["Plural-Forms"])
+			return
+		}
+		if e.fuzzy {
+			return
+		}
+		forms := make([]string, len(e.strs))
+		for i, s := range e.strs {
+			if i < len(forms) {
+				forms[i] = s
+			}
+		}
+		if key := messageKey(e.ctx, e.id); len(forms) > 0 && strings.Join(forms, "") != "" {
+			c.messages[key] = forms
+		}
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(b))
+	lineno := 0
+	for scanner.Scan() {
+		lineno++
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case line == "":
+			flush()
+			continue
+		case strings.HasPrefix(line, "#,"):
+			if e.last != nil {
+				flush()
+			}
+			e.fuzzy = strings.Contains(line, "fuzzy")
+			continue
+		case strings.HasPrefix(line, "#"):
+			continue
+		}
+		keyword, quoted := line, ""
+		if i := strings.Index(line, `"`); i >= 0 {
+			keyword, quoted = strings.TrimSpace(line[:i]), line[i:]
+		}
+		value, uerr := strconv.Unquote(quoted)
+		if uerr != nil {
+			return nil, false, CatalogueParseError(name, lineno)
+		}
+		switch {
+		case keyword == "":
+			if e.last == nil {
+				return nil, false, CatalogueParseError(name, lineno)
+			}
+			e.last(value)
+		case keyword == "msgctxt":
+			if e.id != "" || len(e.strs) > 0 {
+				flush()
+			}
+			e.ctx = value
+			e.last = func(v string) { e.ctx += v }
+		case keyword == "msgid":
+			if len(e.strs) > 0 {
+				flush()
+			}
+			e.id = value
+			e.last = func(v string) { e.id += v }
+		case keyword == "msgid_plural":
+			e.plural = value
+			e.last = func(v string) { e.plural += v }
+		case keyword == "msgstr" || strings.HasPrefix(keyword, "msgstr["):
+			i := 0
+			if keyword != "msgstr" {
+				n, aerr := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(keyword, "msgstr["), "]"))
+				if aerr != nil || n < 0 {
+					return nil, false, CatalogueParseError(name, lineno)
+				}
+				i = n
+			}
+			e.strs[i] = value
+			e.last = func(v string) { e.strs[i] += v }
+		default:
+			return nil, false, CatalogueParseError(name, lineno)
+		}
+	}
+	flush()
+	if err != nil {
+		return nil, false, err
+	}
+	return c, own, scanner.Err()
+}
+
+func messageKey(ctx, msgid string) string {
+	if ctx == "" {
+		return msgid
+	}
+	return ctx + "\x04" + msgid
+}
+
+// parseJSON parses a JSON catalogue, an object of message ids to a string, or
+// to an array of plural forms, with an optional header object keyed by the
+// empty string giving the language and plural forms, e.g.
+//
+//	{"": {"language": "fr", "plural-forms": "nplurals=2; plural=(n > 1);"},
+//	 "Hello": "Bonjour", "%d file": ["%d fichier", "%d fichiers"]}
+func parseJSON(b []byte, name string) (*catalogue, bool, error) {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return nil, false, CatalogueParseError(name, 1)
+	}
+	c := newCatalogue(localeFromName(name))
+	var own bool
+	for k, v := range raw {
+		if k == "" {
+			var h map[string]string
+			if err := json.Unmarshal(v, &h); err != nil {
+				return nil, false, CatalogueParseError(name, 1)
+			}
+			var err error
+			if own, err = c.header(h["language"], h["plural-forms"]); err != nil {
+				return nil, false, err
+			}
+			continue
+		}
+		var s string
+		if err := json.Unmarshal(v, &s); err == nil {
+			c.messages[k] = []string{s}
+			continue
+		}
+		var forms []string
+		if err := json.Unmarshal(v, &forms); err != nil {
+			return nil, false, CatalogueParseError(name, 1)
+		}
+		c.messages[k] = forms
+	}
+	return c, own, nil
+}
+
+// LoadByte loads a catalogue from bytes, with the name of the file it was
+// read from determining its format and, without a header giving it, its
+// locale.
+func (i *i18n) LoadByte(b []byte, name string) error {
+	c, own, err := parseCatalogue(b, name)
+	if err != nil {
+		return err
+	}
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if existing, ok := i.catalogues[c.locale]; ok {
+		existing.merge(c, own)
+		return nil
+	}
+	i.catalogues[c.locale] = c
+	return nil
+}
+
+// LoadFile loads a PO or JSON catalogue file.
+func (i *i18n) LoadFile(filename string) error {
+	b, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+	return i.LoadByte(b, filename)
+}
+
+// LoadDir loads every PO and JSON catalogue in a directory and beneath it.
+func (i *i18n) LoadDir(dir string) error {
+	return filepath.Walk(dir, func(p string, fi os.FileInfo, err error) error {
+		if err != nil || fi.IsDir() || !isCatalogue(p) {
+			return err
+		}
+		return i.LoadFile(p)
+	})
+}
+
+// LoadAssets loads every PO and JSON catalogue in the asset FSs with a name
+// beginning with the prefix, e.g. locales/.
+func (i *i18n) LoadAssets(prefix string) error {
+	for _, fs := range i.a.ListAssetFS() {
+		for _, name := range fs.AssetNames() {
+			if !strings.HasPrefix(name, prefix) || !isCatalogue(name) {
+				continue
+			}
+			b, err := fs.Asset(name)
+			if err != nil {
+				return err
+			}
+			if err := i.LoadByte(b, name); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Load loads the catalogues of the i18n_directories setting, and of assets
+// beneath the i18n_assets setting.
+func (i *i18n) Load() error {
+	for _, dir := range i.s.List("i18n_directories") {
+		if err := i.LoadDir(dir); err != nil {
+			return err
+		}
+	}
+	if prefix := i.s.String("i18n_assets"); prefix != "" {
+		return i.LoadAssets(prefix)
+	}
+	return nil
+}
@@ -0,0 +1,3 @@
+// Package i18n contains translation catalogues, locale negotiation and
+// locale aware formatting for flotilla states and templates.
+package i18n
@@ -0,0 +1,86 @@
+package i18n
+
+import (
+	"time"
+
+	"github.com/flxtilla/cxre/extension"
+	"github.com/flxtilla/cxre/state"
+)
+
+// I18nExtension returns an extension providing the locale, set_locale, _,
+// gettext, ngettext, pgettext, format_number and format_date functions to a
+// state, translating into the locale negotiated for the state.
+func (i *i18n) I18nExtension() extension.Extension {
+	gettext := func(s state.State, msgid string, args ...interface{}) string {
+		return i.Gettext(i.Negotiate(s), msgid, args...)
+	}
+	return extension.New(
+		"I18n_Extension",
+		extension.NewFunction("locale", i.Negotiate),
+		extension.NewFunction("set_locale", i.SetLocale),
+		extension.NewFunction("_", gettext),
+		extension.NewFunction("gettext", gettext),
+		extension.NewFunction("ngettext", func(s state.State, singular, plural string, n int, args ...interface{}) string {
+			return i.NGettext(i.Negotiate(s), singular, plural, n, args...)
+		}),
+		extension.NewFunction("pgettext", func(s state.State, ctx, msgid string, args ...interface{}) string {
+			return i.PGettext(i.Negotiate(s), ctx, msgid, args...)
+		}),
+		extension.NewFunction("format_number", func(s state.State, v float64, decimals int) string {
+			return FormatNumber(i.Negotiate(s), v, decimals)
+		}),
+		extension.NewFunction("format_date", func(s state.State, t time.Time, style string) string {
+			return FormatDate(i.Negotiate(s), t, style)
+		}),
+	)
+}
+
+// TemplateFunctions returns the _, ngettext, pgettext, format_number and
+// format_date functions for use as template functions, e.g. with a Templater
+// AddTemplateFunctions. The first argument of each is a locale, or the
+// template data of a request, e.g. {{ _ . "Hello %s" .Data.Name }}.
+func (i *i18n) TemplateFunctions() map[string]interface{} {
+	return map[string]interface{}{
+		"_": func(l interface{}, msgid string, args ...interface{}) string {
+			return i.Gettext(i.localeOf(l), msgid, args...)
+		},
+		"ngettext": func(l interface{}, singular, plural string, n int, args ...interface{}) string {
+			return i.NGettext(i.localeOf(l), singular, plural, n, args...)
+		},
+		"pgettext": func(l interface{}, ctx, msgid string, args ...interface{}) string {
+			return i.PGettext(i.localeOf(l), ctx, msgid, args...)
+		},
+		"format_number": func(l interface{}, v float64, decimals int) string {
+			return FormatNumber(i.localeOf(l), v, decimals)
+		},
+		"format_date": func(l interface{}, t time.Time, style string) string {
+			return FormatDate(i.localeOf(l), t, style)
+		},
+	}
+}
+
+// localeOf returns a locale given as a string, or negotiated for a state.
+// Template data rendered outside of a request has no state, and is given the
+// default locale.
+func (i *i18n) localeOf(l interface{}) string {
+	switch v := l.(type) {
+	case string:
+		return v
+	case state.State:
+		if !stateless(v) {
+			return i.Negotiate(v)
+		}
+	}
+	return i.defaultLocale()
+}
+
+// stateless returns a boolean indicating if a state is template data holding
+// no state, e.g. rendered outside of a request.
+func stateless(s state.State) bool {
+	if d, ok := s.(interface {
+		HasState() bool
+	}); ok {
+		return !d.HasState()
+	}
+	return false
+}
@@ -0,0 +1,97 @@
+package i18n
+
+import (
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// conventions are the number and date conventions of a language.
+type conventions struct {
+	decimal, group string
+	short, medium  string
+	long           string
+	months         [12]string
+}
+
+var englishMonths = [12]string{"January", "February", "March", "April", "May", "June", "July", "August", "September", "October", "November", "December"}
+
+var languageConventions = map[string]conventions{
+	"en": {".", ",", "01/02/2006", "Jan 2, 2006", "January 2, 2006", englishMonths},
+	"de": {",", ".", "02.01.2006", "2. Jan 2006", "2. January 2006", [12]string{"Januar", "Februar", "März", "April", "Mai", "Juni", "Juli", "August", "September", "Oktober", "November", "Dezember"}},
+	"es": {",", ".", "02/01/2006", "2 Jan 2006", "2 de January de 2006", [12]string{"enero", "febrero", "marzo", "abril", "mayo", "junio", "julio", "agosto", "septiembre", "octubre", "noviembre", "diciembre"}},
+	"fr": {",", " ", "02/01/2006", "2 Jan 2006", "2 January 2006", [12]string{"janvier", "février", "mars", "avril", "mai", "juin", "juillet", "août", "septembre", "octobre", "novembre", "décembre"}},
+	"it": {",", ".", "02/01/2006", "2 Jan 2006", "2 January 2006", [12]string{"gennaio", "febbraio", "marzo", "aprile", "maggio", "giugno", "luglio", "agosto", "settembre", "ottobre", "novembre", "dicembre"}},
+	"nl": {",", ".", "02-01-2006", "2 Jan 2006", "2 January 2006", [12]string{"januari", "februari", "maart", "april", "mei", "juni", "juli", "augustus", "september", "oktober", "november", "december"}},
+	"pt": {",", ".", "02/01/2006", "2 Jan 2006", "2 de January de 2006", [12]string{"janeiro", "fevereiro", "março", "abril", "maio", "junho", "julho", "agosto", "setembro", "outubro", "novembro", "dezembro"}},
+}
+
+func conventionsFor(locale string) conventions {
+	if c, ok := languageConventions[language(locale)]; ok {
+		return c
+	}
+	return languageConventions["en"]
+}
+
+// FormatNumber formats a number for a locale, rounded to the number of
+// decimals, with the decimal and grouping separators of its language.
+func FormatNumber(locale string, v float64, decimals int) string {
+	c := conventionsFor(locale)
+	if decimals < 0 {
+		decimals = 0
+	}
+	s := strconv.FormatFloat(math.Abs(v), 'f', decimals, 64)
+	integer, fraction := s, ""
+	if i := strings.Index(s, "."); i >= 0 {
+		integer, fraction = s[:i], s[i+1:]
+	}
+	var b strings.Builder
+	if v < 0 && strings.Trim(s, "0.") != "" {
+		b.WriteString("-")
+	}
+	for n, d := range integer {
+		if n > 0 && (len(integer)-n)%3 == 0 {
+			b.WriteString(c.group)
+		}
+		b.WriteRune(d)
+	}
+	if fraction != "" {
+		b.WriteString(c.decimal)
+		b.WriteString(fraction)
+	}
+	return b.String()
+}
+
+// FormatDate formats a time for a locale in the short, medium or long date
+// style of its language, or with any other style as a time layout, with
+// month names in the language.
+func FormatDate(locale string, t time.Time, style string) string {
+	c := conventionsFor(locale)
+	layout := style
+	switch style {
+	case "short":
+		layout = c.short
+	case "medium":
+		layout = c.medium
+	case "long":
+		layout = c.long
+	}
+	month := int(t.Month()) - 1
+	out := t.Format(layout)
+	if strings.Contains(layout, "January") {
+		out = strings.Replace(out, englishMonths[month], c.months[month], -1)
+	} else if strings.Contains(layout, "Jan") {
+		out = strings.Replace(out, englishMonths[month][:3], abbreviate(c.months[month]), -1)
+	}
+	return out
+}
+
+// abbreviate returns the abbreviated form of a month name.
+func abbreviate(month string) string {
+	r := []rune(month)
+	if len(r) <= 4 {
+		return month
+	}
+	return string(r[:3]) + "."
+}
@@ -0,0 +1,316 @@
+package i18n
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/flxtilla/cxre/asset"
+	"github.com/flxtilla/cxre/extension"
+	"github.com/flxtilla/cxre/state"
+	"github.com/flxtilla/cxre/store"
+)
+
+// I18n translates messages from catalogues into the locale negotiated for a
+// request, and provides translation and formatting functions to states and
+// templates.
+type I18n interface {
+	Catalogues
+	Translator
+	Negotiator
+	I18nExtension() extension.Extension
+	TemplateFunctions() map[string]interface{}
+}
+
+// Catalogues loads translation catalogues, gettext PO files or JSON files,
+// from bytes, files, directories and asset FSs.
+type Catalogues interface {
+	LoadByte([]byte, string) error
+	LoadFile(string) error
+	LoadDir(string) error
+	LoadAssets(string) error
+	Load() error
+	Locales() []string
+}
+
+// Translator translates messages into a locale.
+type Translator interface {
+	Gettext(string, string, ...interface{}) string
+	NGettext(string, string, string, int, ...interface{}) string
+	PGettext(string, string, string, ...interface{}) string
+}
+
+// Negotiator chooses the locale for a state.
+type Negotiator interface {
+	Negotiate(state.State) string
+	SetLocale(state.State, string) error
+}
+
+var i18nSettings = []store.Setting{
+	{
+		Name:        "i18n_default_locale",
+		Default:     "en",
+		Description: "Locale used when no supported locale is negotiated.",
+		Subsystem:   "i18n",
+	},
+	{
+		Name:        "i18n_locales",
+		Type:        store.TypeList,
+		Description: "Supported locales; every loaded catalogue when empty.",
+		Subsystem:   "i18n",
+	},
+	{
+		Name:        "i18n_directories",
+		Type:        store.TypeList,
+		Description: "Directories catalogues are loaded from.",
+		Subsystem:   "i18n",
+	},
+	{
+		Name:        "i18n_assets",
+		Description: "Prefix of asset names catalogues are loaded from, e.g. locales/.",
+		Subsystem:   "i18n",
+	},
+	{
+		Name:        "i18n_session_key",
+		Default:     "locale",
+		Description: "Session key holding a chosen locale.",
+		Subsystem:   "i18n",
+	},
+	{
+		Name:        "i18n_url_prefix",
+		Type:        store.TypeBool,
+		Default:     "false",
+		Description: "Whether a leading path segment, e.g. /fr/, chooses the locale. The segment is not stripped, routes must include it, e.g. /:locale/about.",
+		Subsystem:   "i18n",
+	},
+}
+
+type i18n struct {
+	s          store.Store
+	a          asset.Assets
+	mu         sync.RWMutex
+	catalogues map[string]*catalogue
+}
+
+// New returns an I18n using the settings of the store, without catalogues
+// until they are loaded, e.g. by Load from the i18n_directories and
+// i18n_assets settings.
+func New(s store.Store, a asset.Assets) I18n {
+	store.MustRegister(s, i18nSettings...)
+	return &i18n{
+		s:          s,
+		a:          a,
+		catalogues: make(map[string]*catalogue),
+	}
+}
+
+// Normalize returns a locale in the form used by the catalogues, being lower
+// cased with dashes, without any encoding or modifier, e.g. pt-br for
+// pt_BR.UTF-8.
+func Normalize(locale string) string {
+	if i := strings.IndexAny(locale, ".@"); i >= 0 {
+		locale = locale[:i]
+	}
+	return strings.ToLower(strings.Replace(strings.TrimSpace(locale), "_", "-", -1))
+}
+
+// language returns the language of a locale, e.g. pt for pt-br.
+func language(locale string) string {
+	locale = Normalize(locale)
+	if i := strings.Index(locale, "-"); i >= 0 {
+		return locale[:i]
+	}
+	return locale
+}
+
+func (i *i18n) defaultLocale() string {
+	return Normalize(i.s.String("i18n_default_locale"))
+}
+
+// Locales returns the locales of every loaded catalogue, sorted.
+func (i *i18n) Locales() []string {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	var ret []string
+	for l := range i.catalogues {
+		ret = append(ret, l)
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+// supported returns the supported locales, being those of the i18n_locales
+// setting, or every loaded locale and the default locale.
+func (i *i18n) supported() []string {
+	var ret []string
+	for _, l := range i.s.List("i18n_locales") {
+		ret = append(ret, Normalize(l))
+	}
+	if len(ret) > 0 {
+		return ret
+	}
+	ret = i.Locales()
+	if d := i.defaultLocale(); !contains(ret, d) {
+		ret = append(ret, d)
+	}
+	return ret
+}
+
+func contains(ss []string, s string) bool {
+	for _, x := range ss {
+		if x == s {
+			return true
+		}
+	}
+	return false
+}
+
+// match returns the supported locale for a requested locale: the same
+// locale, or its language, or another locale of its language.
+func match(supported []string, requested string) (string, bool) {
+	requested = Normalize(requested)
+	if requested == "" {
+		return "", false
+	}
+	if contains(supported, requested) {
+		return requested, true
+	}
+	lang := language(requested)
+	if contains(supported, lang) {
+		return lang, true
+	}
+	for _, l := range supported {
+		if language(l) == lang {
+			return l, true
+		}
+	}
+	return "", false
+}
+
+// chain returns the locales to look for a message in, in order.
+func (i *i18n) chain(locale string) []*catalogue {
+	locale = Normalize(locale)
+	var ret []*catalogue
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	for _, l := range []string{locale, language(locale), i.defaultLocale()} {
+		if c, ok := i.catalogues[l]; ok && (len(ret) == 0 || ret[len(ret)-1] != c) {
+			ret = append(ret, c)
+		}
+	}
+	return ret
+}
+
+func (i *i18n) translate(locale, key, singular, plural string, n int) string {
+	for _, c := range i.chain(locale) {
+		if t, ok := c.translation(key, n); ok {
+			return t
+		}
+	}
+	if n != 1 && plural != "" {
+		return plural
+	}
+	return singular
+}
+
+func format(msg string, args []interface{}) string {
+	if len(args) == 0 {
+		return msg
+	}
+	return fmt.Sprintf(msg, args...)
+}
+
+// Gettext returns the translation of the message into the locale, formatted
+// with any arguments. A message is looked for in the catalogues of the
+// locale, its language and the default locale, and is returned untranslated
+// when none translate it.
+func (i *i18n) Gettext(locale, msgid string, args ...interface{}) string {
+	return format(i.translate(locale, msgid, msgid, "", 1), args)
+}
+
+// NGettext returns the plural form of the translation for the count. With no
+// arguments a translation containing %d is formatted with the count.
+func (i *i18n) NGettext(locale, singular, plural string, n int, args ...interface{}) string {
+	msg := i.translate(locale, singular, singular, plural, n)
+	if len(args) == 0 && strings.Contains(msg, "%d") {
+		args = []interface{}{n}
+	}
+	return format(msg, args)
+}
+
+// PGettext returns the translation of the message in a context, e.g. a
+// message used as both a noun and a verb.
+func (i *i18n) PGettext(locale, ctx, msgid string, args ...interface{}) string {
+	return format(i.translate(locale, messageKey(ctx, msgid), msgid, "", 1), args)
+}
+
+// Negotiate returns the locale for a state, chosen from the supported
+// locales by, in order, the leading path segment where the i18n_url_prefix
+// setting is true, the session, and the Accept-Language header, or the
+// default locale. The leading segment is not stripped from the path, so
+// routes for prefixed paths include it, e.g. as a :locale parameter.
+func (i *i18n) Negotiate(s state.State) string {
+	supported := i.supported()
+	rq := s.Request()
+	if rq != nil && i.s.Bool("i18n_url_prefix") {
+		segment := strings.SplitN(strings.TrimPrefix(rq.URL.Path, "/"), "/", 2)[0]
+		if contains(supported, Normalize(segment)) {
+			return Normalize(segment)
+		}
+	}
+	if l, ok := s.Get(i.s.String("i18n_session_key")).(string); ok {
+		if m, ok := match(supported, l); ok {
+			return m
+		}
+	}
+	if rq != nil {
+		for _, l := range AcceptLanguage(rq.Header.Get("Accept-Language")) {
+			if m, ok := match(supported, l); ok {
+				return m
+			}
+		}
+	}
+	return i.defaultLocale()
+}
+
+// SetLocale stores a locale in the session of a state, choosing it for later
+// requests.
+func (i *i18n) SetLocale(s state.State, locale string) error {
+	return s.Set(i.s.String("i18n_session_key"), Normalize(locale))
+}
+
+type weighted struct {
+	locale string
+	q      float64
+}
+
+// AcceptLanguage returns the locales of an Accept-Language header in order of
+// preference, omitting the wildcard and any locale with a zero quality.
+func AcceptLanguage(header string) []string {
+	var ws []weighted
+	for _, part := range strings.Split(header, ",") {
+		fields := strings.Split(strings.TrimSpace(part), ";")
+		l := strings.TrimSpace(fields[0])
+		q := 1.0
+		for _, f := range fields[1:] {
+			if f = strings.TrimSpace(f); strings.HasPrefix(f, "q=") {
+				if v, err := strconv.ParseFloat(f[2:], 64); err == nil {
+					q = v
+				}
+			}
+		}
+		if l != "" && l != "*" && q > 0 {
+			ws = append(ws, weighted{l, q})
+		}
+	}
+	sort.SliceStable(ws, func(a, b int) bool {
+		return ws[a].q > ws[b].q
+	})
+	ret := make([]string, 0, len(ws))
+	for _, w := range ws {
+		ret = append(ret, Normalize(w.locale))
+	}
+	return ret
+}
@@ -0,0 +1,201 @@
+package i18n
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/flxtilla/cxre/asset"
+	"github.com/flxtilla/cxre/engine"
+	"github.com/flxtilla/cxre/extension"
+	"github.com/flxtilla/cxre/log"
+	"github.com/flxtilla/cxre/state"
+	"github.com/flxtilla/cxre/store"
+)
+
+const testPO = `# French
+msgid ""
+msgstr ""
+"Language: fr\n"
+"Plural-Forms: nplurals=2; plural=(n > 1);\n"
+
+#: page.html:1
+msgid "Hello %s"
+msgstr "Bonjour %s"
+
+msgid "%d file"
+msgid_plural "%d files"
+msgstr[0] "%d fichier"
+msgstr[1] "%d fichiers"
+
+msgctxt "verb"
+msgid "Open"
+msgstr "Ouvrir"
+
+#, fuzzy
+msgid "Unsure"
+msgstr "Incertain"
+
+msgid "Long"
+msgstr "Un message "
+"sur deux lignes"
+
+msgid "Untranslated"
+msgstr ""
+`
+
+type testAssetFS map[string]string
+
+func (fs testAssetFS) Asset(name string) ([]byte, error) {
+	if v, ok := fs[name]; ok {
+		return []byte(v), nil
+	}
+	return nil, os.ErrNotExist
+}
+
+func (fs testAssetFS) AssetHttp(name string) (http.File, error) {
+	return nil, os.ErrNotExist
+}
+
+func (fs testAssetFS) AssetDir(name string) ([]string, error) {
+	return nil, os.ErrNotExist
+}
+
+func (fs testAssetFS) AssetNames() []string {
+	var names []string
+	for k := range fs {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func TestCatalogues(t *testing.T) {
+	dir, err := ioutil.TempDir("", "locales")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	ioutil.WriteFile(filepath.Join(dir, "messages.po"), []byte(testPO), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "pl.json"), []byte(`{"%d file": ["%d plik", "%d pliki", "%d plików"]}`), 0644)
+
+	s := store.New()
+	s.Add("i18n_directories", dir)
+	s.Add("i18n_assets", "locales/")
+	i := New(s, asset.New(testAssetFS{
+		"locales/de.json": `{"": {"language": "de"}, "Hello %s": "Hallo %s"}`,
+		"other/es.json":   `{"Hello %s": "Hola %s"}`,
+	}))
+	if err := i.Load(); err != nil {
+		t.Fatal(err)
+	}
+	if l := strings.Join(i.Locales(), ","); l != "de,fr,pl" {
+		t.Errorf("Locales were %s", l)
+	}
+
+	for _, c := range []struct{ got, expected string }{
+		{i.Gettext("fr", "Hello %s", "Ann"), "Bonjour Ann"},
+		{i.Gettext("fr-CA", "Hello %s", "Ann"), "Bonjour Ann"},
+		{i.Gettext("de_DE.UTF-8", "Hello %s", "Ann"), "Hallo Ann"},
+		{i.Gettext("es", "Hello %s", "Ann"), "Hello Ann"},
+		{i.Gettext("fr", "Long"), "Un message sur deux lignes"},
+		{i.Gettext("fr", "Unsure"), "Unsure"},
+		{i.Gettext("fr", "Untranslated"), "Untranslated"},
+		{i.Gettext("fr", "Open"), "Open"},
+		{i.PGettext("fr", "verb", "Open"), "Ouvrir"},
+		{i.NGettext("fr", "%d file", "%d files", 0), "0 fichier"},
+		{i.NGettext("fr", "%d file", "%d files", 2), "2 fichiers"},
+		{i.NGettext("en", "%d file", "%d files", 2), "2 files"},
+		{i.NGettext("pl", "%d file", "%d files", 3), "3 pliki"},
+		{i.NGettext("pl", "%d file", "%d files", 5), "5 plików"},
+		{i.NGettext("pl", "%d file", "%d files", 22), "22 pliki"},
+	} {
+		if c.got != c.expected {
+			t.Errorf("Translated %q, not %q", c.got, c.expected)
+		}
+	}
+
+	if err := i.LoadByte([]byte("msgid \"a\"\nbroken"), "fr.po"); err == nil {
+		t.Error("LoadByte of a broken catalogue did not return an error")
+	}
+}
+
+func TestPluralForms(t *testing.T) {
+	p, err := ParsePluralForms(pluralRules["ar"])
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []int
+	for _, n := range []int{0, 1, 2, 5, 11, 100} {
+		got = append(got, p(n))
+	}
+	if fmt.Sprint(got) != "[0 1 2 3 4 5]" {
+		t.Errorf("Arabic plural forms were %v", got)
+	}
+	for _, bad := range []string{"nplurals=2;", "nplurals=2; plural=(n > ;", "nplurals=2; plural=n ? 1;"} {
+		if _, err := ParsePluralForms(bad); err == nil {
+			t.Errorf("ParsePluralForms(%q) did not return an error", bad)
+		}
+	}
+}
+
+func TestNegotiation(t *testing.T) {
+	if l := strings.Join(AcceptLanguage("fr-CH, fr;q=0.9, en;q=0.8, de;q=0, *;q=0.5"), ","); l != "fr-ch,fr,en" {
+		t.Errorf("AcceptLanguage was %s", l)
+	}
+	supported := []string{"en", "fr", "pt-br"}
+	for requested, expected := range map[string]string{"fr-CH": "fr", "pt": "pt-br", "PT_br": "pt-br", "de": ""} {
+		if m, _ := match(supported, requested); m != expected {
+			t.Errorf("match(%s) was %q, not %q", requested, m, expected)
+		}
+	}
+	i := New(store.New(), asset.New()).(*i18n)
+	if l := i.localeOf(statelessData{}); l != i.defaultLocale() {
+		t.Errorf("locale of template data without a state was %q", l)
+	}
+	if l := i.localeOf("pt-br"); l != "pt-br" {
+		t.Errorf("locale of a string was %q", l)
+	}
+
+	s := store.New()
+	s.Add("i18n_locales", "en,fr")
+	s.Add("i18n_url_prefix", "true")
+	i = New(s, asset.New()).(*i18n)
+	st := state.New(extension.New("I18n_Test_Extension"), engine.NewResult(200, nil, engine.Params{{Key: "locale", Value: "fr"}}, false), log.New(ioutil.Discard, log.LInfo, log.DefaultNullFormatter()))
+	st.Reset(httptest.NewRequest("GET", "/fr/about", nil), httptest.NewRecorder(), nil)
+	if l := i.Negotiate(st); l != "fr" {
+		t.Errorf("locale of /fr/about routed as /:locale/about was %q", l)
+	}
+}
+
+type statelessData struct {
+	state.State
+}
+
+func (d statelessData) HasState() bool { return d.State != nil }
+
+func TestFormatting(t *testing.T) {
+	date := time.Date(2016, time.March, 5, 0, 0, 0, 0, time.UTC)
+	for _, c := range []struct{ got, expected string }{
+		{FormatNumber("en", 1234567.891, 2), "1,234,567.89"},
+		{FormatNumber("de", -1234.5, 1), "-1.234,5"},
+		{FormatNumber("fr", 999, 0), "999"},
+		{FormatNumber("en", -0.001, 2), "0.00"},
+		{FormatDate("en", date, "long"), "March 5, 2016"},
+		{FormatDate("fr", date, "long"), "5 mars 2016"},
+		{FormatDate("de", date, "medium"), "5. März 2016"},
+		{FormatDate("es", date, "short"), "05/03/2016"},
+		{FormatDate("pt", date, "January 2006"), "março 2016"},
+	} {
+		if c.got != c.expected {
+			t.Errorf("Formatted %q, not %q", c.got, c.expected)
+		}
+	}
+}
@@ -0,0 +1,263 @@
+package i18n
+
+import (
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/flxtilla/cxre/xrr"
+)
+
+var (
+	BadPluralForms  = xrr.NewXrror("Unable to parse plural forms %q: %s").Out
+	unexpectedEnd   = xrr.NewXrror("unexpected end of expression")
+	unexpectedToken = xrr.NewXrror("unexpected %q at %d").Out
+)
+
+// Plural returns the index of the plural form to use for a count.
+type Plural func(int) int
+
+// pluralRules are the gettext plural forms of common languages, used for
+// catalogues that do not give their own.
+var pluralRules = map[string]string{
+	"ar": "nplurals=6; plural=(n==0 ? 0 : n==1 ? 1 : n==2 ? 2 : n%100>=3 && n%100<=10 ? 3 : n%100>=11 ? 4 : 5);",
+	"cs": "nplurals=3; plural=(n==1) ? 0 : (n>=2 && n<=4) ? 1 : 2;",
+	"de": "nplurals=2; plural=(n != 1);",
+	"en": "nplurals=2; plural=(n != 1);",
+	"es": "nplurals=2; plural=(n != 1);",
+	"fr": "nplurals=2; plural=(n > 1);",
+	"it": "nplurals=2; plural=(n != 1);",
+	"ja": "nplurals=1; plural=0;",
+	"ko": "nplurals=1; plural=0;",
+	"nl": "nplurals=2; plural=(n != 1);",
+	"pl": "nplurals=3; plural=(n==1 ? 0 : n%10>=2 && n%10<=4 && (n%100<10 || n%100>=20) ? 1 : 2);",
+	"pt": "nplurals=2; plural=(n != 1);",
+	"ru": "nplurals=3; plural=(n%10==1 && n%100!=11 ? 0 : n%10>=2 && n%10<=4 && (n%100<10 || n%100>=20) ? 1 : 2);",
+	"tr": "nplurals=2; plural=(n > 1);",
+	"uk": "nplurals=3; plural=(n%10==1 && n%100!=11 ? 0 : n%10>=2 && n%10<=4 && (n%100<10 || n%100>=20) ? 1 : 2);",
+	"zh": "nplurals=1; plural=0;",
+}
+
+// pluralFor returns the plural forms of the language of a locale, being
+// those of English where the language is not known.
+func pluralFor(locale string) Plural {
+	rule, ok := pluralRules[language(locale)]
+	if !ok {
+		rule = pluralRules["en"]
+	}
+	p, _ := ParsePluralForms(rule)
+	return p
+}
+
+// ParsePluralForms parses a gettext Plural-Forms header value, e.g.
+// "nplurals=2; plural=(n != 1);", returning the Plural it describes.
+func ParsePluralForms(forms string) (Plural, error) {
+	var nplurals int
+	var expr string
+	for _, part := range strings.Split(forms, ";") {
+		kv := strings.SplitN(strings.TrimSpace(part), "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		switch strings.TrimSpace(kv[0]) {
+		case "nplurals":
+			nplurals, _ = strconv.Atoi(strings.TrimSpace(kv[1]))
+		case "plural":
+			expr = kv[1]
+		}
+	}
+	if nplurals < 1 || expr == "" {
+		return nil, BadPluralForms(forms, "nplurals and plural are required")
+	}
+	p := &pluralParser{src: expr}
+	e, err := p.parse()
+	if err != nil {
+		return nil, BadPluralForms(forms, err)
+	}
+	return func(n int) int {
+		if i := e(n); i >= 0 && i < nplurals {
+			return i
+		}
+		return 0
+	}, nil
+}
+
+// expr is a compiled plural expression of n.
+type expr func(int) int
+
+type pluralParser struct {
+	src string
+	pos int
+}
+
+func (p *pluralParser) parse() (expr, error) {
+	e, err := p.ternary()
+	if err != nil {
+		return nil, err
+	}
+	if p.skip(); p.pos < len(p.src) {
+		return nil, p.unexpected()
+	}
+	return e, nil
+}
+
+func (p *pluralParser) skip() {
+	for p.pos < len(p.src) && unicode.IsSpace(rune(p.src[p.pos])) {
+		p.pos++
+	}
+}
+
+// accept consumes the token if it is next.
+func (p *pluralParser) accept(tok string) bool {
+	p.skip()
+	if strings.HasPrefix(p.src[p.pos:], tok) {
+		p.pos += len(tok)
+		return true
+	}
+	return false
+}
+
+func (p *pluralParser) unexpected() error {
+	if p.pos >= len(p.src) {
+		return unexpectedEnd
+	}
+	return unexpectedToken(p.src[p.pos:p.pos+1], p.pos)
+}
+
+func (p *pluralParser) ternary() (expr, error) {
+	cond, err := p.binary(0)
+	if err != nil || !p.accept("?") {
+		return cond, err
+	}
+	a, err := p.ternary()
+	if err != nil {
+		return nil, err
+	}
+	if !p.accept(":") {
+		return nil, p.unexpected()
+	}
+	b, err := p.ternary()
+	if err != nil {
+		return nil, err
+	}
+	return func(n int) int {
+		if cond(n) != 0 {
+			return a(n)
+		}
+		return b(n)
+	}, nil
+}
+
+// operators are the binary operators by increasing precedence; operators of
+// a level sharing a prefix list the longer first.
+var operators = [][]string{
+	{"||"},
+	{"&&"},
+	{"==", "!="},
+	{"<=", ">=", "<", ">"},
+	{"+", "-"},
+	{"*", "/", "%"},
+}
+
+func (p *pluralParser) binary(level int) (expr, error) {
+	if level == len(operators) {
+		return p.unary()
+	}
+	left, err := p.binary(level + 1)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		op := ""
+		for _, o := range operators[level] {
+			if p.accept(o) {
+				op = o
+				break
+			}
+		}
+		if op == "" {
+			return left, nil
+		}
+		right, err := p.binary(level + 1)
+		if err != nil {
+			return nil, err
+		}
+		left = apply(op, left, right)
+	}
+}
+
+func boolInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+func apply(op string, a, b expr) expr {
+	return func(n int) int {
+		x, y := a(n), b(n)
+		switch op {
+		case "||":
+			return boolInt(x != 0 || y != 0)
+		case "&&":
+			return boolInt(x != 0 && y != 0)
+		case "==":
+			return boolInt(x == y)
+		case "!=":
+			return boolInt(x != y)
+		case "<=":
+			return boolInt(x <= y)
+		case ">=":
+			return boolInt(x >= y)
+		case "<":
+			return boolInt(x < y)
+		case ">":
+			return boolInt(x > y)
+		case "+":
+			return x + y
+		case "-":
+			return x - y
+		case "*":
+			return x * y
+		}
+		if y == 0 {
+			return 0
+		}
+		if op == "/" {
+			return x / y
+		}
+		return x % y
+	}
+}
+
+func (p *pluralParser) unary() (expr, error) {
+	if p.accept("!") {
+		e, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		return func(n int) int { return boolInt(e(n) == 0) }, nil
+	}
+	if p.accept("(") {
+		e, err := p.ternary()
+		if err != nil {
+			return nil, err
+		}
+		if !p.accept(")") {
+			return nil, p.unexpected()
+		}
+		return e, nil
+	}
+	if p.accept("n") {
+		return func(n int) int { return n }, nil
+	}
+	start := p.pos
+	for p.pos < len(p.src) && p.src[p.pos] >= '0' && p.src[p.pos] <= '9' {
+		p.pos++
+	}
+	if start == p.pos {
+		return nil, p.unexpected()
+	}
+	v, _ := strconv.Atoi(p.src[start:p.pos])
+	return func(int) int { return v }, nil
+}
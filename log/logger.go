@@ -5,6 +5,7 @@ import (
 	"io"
 	"os"
 	"sync"
+	"sync/atomic"
 )
 
 type StdLogger interface {
@@ -30,6 +31,7 @@ type Logger interface {
 	Log(Level, Entry)
 	Mutex
 	Level() Level
+	SetLevel(Level)
 	Formatter
 	Hooks
 }
@@ -40,7 +42,7 @@ type Formatter interface {
 
 type logger struct {
 	io.Writer
-	level Level
+	level int32
 	Formatter
 	Hooks
 	sync.Mutex
@@ -49,14 +51,19 @@ type logger struct {
 func New(w io.Writer, l Level, f Formatter) Logger {
 	return &logger{
 		Writer:    w,
-		level:     l,
+		level:     int32(l),
 		Formatter: f,
 		Hooks:     &hooks{},
 	}
 }
 
 func (l *logger) Level() Level {
-	return l.level
+	return Level(atomic.LoadInt32(&l.level))
+}
+
+// SetLevel sets the level of the logger, safely while it is logging.
+func (l *logger) SetLevel(lv Level) {
+	atomic.StoreInt32(&l.level, int32(lv))
 }
 
 func (l *logger) Log(lv Level, e Entry) {
@@ -91,35 +98,35 @@ func log(lv Level, e Entry) {
 }
 
 func (l *logger) Fatal(v ...interface{}) {
-	if l.level == LFatal {
+	if l.Level() == LFatal {
 		log(LFatal, newEntry(l, mkFields(v...)...))
 		os.Exit(1)
 	}
 }
 
 func (l *logger) Fatalf(format string, v ...interface{}) {
-	if l.level == LFatal {
+	if l.Level() == LFatal {
 		log(LFatal, newEntry(l, mkFormatFields(format, v...)...))
 		os.Exit(1)
 	}
 }
 
 func (l *logger) Fatalln(v ...interface{}) {
-	if l.level == LFatal {
+	if l.Level() == LFatal {
 		log(LFatal, newEntry(l, mkFields(v...)...))
 		os.Exit(1)
 	}
 }
 
 func (l *logger) Panic(v ...interface{}) {
-	if l.level == LPanic {
+	if l.Level() == LPanic {
 		log(LPanic, newEntry(l, mkFields(v...)...))
 	}
 	panic(fmt.Sprint(v...))
 }
 
 func (l *logger) Panicf(format string, v ...interface{}) {
-	if l.level == LPanic {
+	if l.Level() == LPanic {
 		log(LPanic, newEntry(l, mkFormatFields(format, v...)...))
 	}
 	panic(fmt.Sprintf(format, v...))
@@ -130,19 +137,19 @@ func (l *logger) Panicln(v ...interface{}) {
 }
 
 func (l *logger) Print(v ...interface{}) {
-	if l.level >= LError {
+	if l.Level() >= LError {
 		log(LInfo, newEntry(l, mkFields(v...)...))
 	}
 }
 
 func (l *logger) Printf(format string, v ...interface{}) {
-	if l.level >= LError {
+	if l.Level() >= LError {
 		log(LInfo, newEntry(l, mkFormatFields(format, v...)...))
 	}
 }
 
 func (l *logger) Println(v ...interface{}) {
-	if l.level >= LError {
+	if l.Level() >= LError {
 		log(LInfo, newEntry(l, mkFields(v...)...))
 	}
 }
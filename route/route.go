@@ -114,6 +114,10 @@ var regParam = regexp.MustCompile(`:[^/#?()\.\\]+|\(\?P<[a-zA-Z0-9]+>.*\)`)
 var regSplat = regexp.MustCompile(`\*[^/#?()\.\\]+|\(\?P<[a-zA-Z0-9]+>.*\)`)
 
 // Url returns a *url.Url for the route, provided the string parameters.
+// Parameters fill the route path parameters and splat in order, path escaped
+// as for UrlNamed; for a GET route any remaining parameters are added to the
+// query string, either as given when of the form key=value, or as
+// valueN=parameter.
 func (rt *Route) Url(params ...string) (*url.URL, error) {
 	paramCount := len(params)
 	i := 0
@@ -123,29 +127,27 @@ func (rt *Route) Url(params ...string) (*url.URL, error) {
 			val = params[i]
 		}
 		i += 1
-		return fmt.Sprintf(`%s`, val)
+		return url.PathEscape(val)
 	})
 	rurl = regSplat.ReplaceAllStringFunc(rurl, func(m string) string {
-		splat := params[i:(len(params))]
+		if i >= paramCount {
+			return ""
+		}
+		splat := params[i:]
 		i += len(splat)
-		return fmt.Sprintf(strings.Join(splat, "/"))
+		return escapeSplat(strings.Join(splat, "/"))
 	})
-	u, err := url.Parse(rurl)
-	if err != nil {
-		return nil, err
-	}
+	u := pathUrl(rurl)
 	if i < len(params) && rt.Method == "GET" {
-		providedquerystring := params[i:(len(params))]
-		var querystring []string
-		qsi := 0
-		for qi, qs := range providedquerystring {
-			if len(strings.Split(qs, "=")) != 2 {
-				qs = fmt.Sprintf("value%d=%s", qi+1, qs)
+		var query []string
+		for qi, qs := range params[i:] {
+			kv := strings.SplitN(qs, "=", 2)
+			if len(kv) != 2 || kv[0] == "" {
+				kv = []string{fmt.Sprintf("value%d", qi+1), qs}
 			}
-			querystring = append(querystring, url.QueryEscape(qs))
-			qsi += 1
+			query = append(query, queryPair(kv[0], kv[1]))
 		}
-		u.RawQuery = strings.Join(querystring, "&")
+		u.RawQuery = strings.Join(query, "&")
 	}
 	return u, nil
 }
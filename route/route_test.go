@@ -87,7 +87,7 @@ func TestRoute(t *testing.T) {
 
 	urls := strings.Join([]string{url1.String(), url2.String(), url3.String(), url4.String(), url5.String()}, ",")
 
-	expected = "/one/parameter_one/,/two/parameter_two?v1%3Danother&also%3Dthis&value3%3Dwith,/stc/static/file/path/splat,/stc2/static/file/path/splat,/random/route/with/a_parameter"
+	expected = "/one/parameter_one%2F,/two/parameter_two?v1=another&also=this&value3=with,/stc/static/file/path/splat,/stc2/static/file/path/splat,/random/route/with/a_parameter"
 
 	if bytes.Compare([]byte(urls), []byte(expected)) != 0 {
 		t.Errorf(`Urls were [%s], but should be %s`, urls, expected)
@@ -95,3 +95,34 @@ func TestRoute(t *testing.T) {
 
 	txst.ZeroExpectationPerformer(t, a, 200, "GET", "/one/test").Perform()
 }
+
+func TestUrlNamed(t *testing.T) {
+	r := route.New(route.DefaultRouteConf("GET", "/users/:id/*path", nil))
+	r.Path = r.Base
+
+	u, err := r.UrlNamed(map[string]string{"id": "a b", "path": "docs/x.txt", "q": "one&two=3", "a": "z"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := "/users/a%20b/docs/x.txt?a=z&q=one%26two%3D3"; u.String() != expected {
+		t.Errorf(`UrlNamed was %s, but should be %s`, u, expected)
+	}
+
+	if _, err := r.UrlNamed(map[string]string{"path": "x"}); err == nil || !strings.Contains(err.Error(), "id") {
+		t.Errorf(`UrlNamed without a parameter returned %v`, err)
+	}
+
+	u, _ = r.Url("1", "a", "b", "k=v w")
+	if expected := "/users/1/a/b/k=v%20w"; u.String() != expected {
+		t.Errorf(`Url was %s, but should be %s`, u, expected)
+	}
+
+	u, _ = r.UrlNamed(map[string]string{"id": "a/b?c#d", "path": "50%/x y/"})
+	if expected := "/users/a%2Fb%3Fc%23d/50%25/x%20y/"; u.String() != expected {
+		t.Errorf(`UrlNamed was %s, but should be %s`, u, expected)
+	}
+	u, _ = r.Url("a/b", "c d", "e/f")
+	if expected := "/users/a%2Fb/c%20d/e/f"; u.String() != expected {
+		t.Errorf(`Url was %s, but should be %s`, u, expected)
+	}
+}
@@ -0,0 +1,90 @@
+package route
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/flxtilla/cxre/xrr"
+)
+
+var MissingUrlParam = xrr.NewXrror("Unable to get url for route %s: no value for parameter %s.").Out
+
+var regParamName = regexp.MustCompile(`^[:*]([^/#?()\.\\]+)$|^\(\?P<([a-zA-Z0-9]+)>`)
+
+// paramName returns the name of a path parameter or splat matched in a route
+// path, e.g. id for :id.
+func paramName(m string) string {
+	if n := regParamName.FindStringSubmatch(m); n != nil {
+		return n[1] + n[2]
+	}
+	return m
+}
+
+// escapeSplat returns a splat value path escaped segment by segment, keeping
+// its slash separators.
+func escapeSplat(v string) string {
+	segments := strings.Split(v, "/")
+	for i, seg := range segments {
+		segments[i] = url.PathEscape(seg)
+	}
+	return strings.Join(segments, "/")
+}
+
+// pathUrl returns a *url.URL for an escaped path, keeping the escaping of
+// each value filling the path.
+func pathUrl(escaped string) *url.URL {
+	p, err := url.PathUnescape(escaped)
+	if err != nil {
+		return &url.URL{Path: escaped}
+	}
+	return &url.URL{Path: p, RawPath: escaped}
+}
+
+func queryPair(k, v string) string {
+	return fmt.Sprintf("%s=%s", url.QueryEscape(k), url.QueryEscape(v))
+}
+
+// UrlNamed returns a *url.URL for the route, provided a map of values by
+// parameter name, e.g. {"id": "1"} for /users/:id. Every path parameter and
+// splat must have a value; a parameter value is path escaped, and a splat
+// value is path escaped segment by segment. For a GET route, values not used
+// in the path are added to the query string sorted by name.
+func (rt *Route) UrlNamed(named map[string]string) (*url.URL, error) {
+	used := make(map[string]bool)
+	var missing string
+	fill := func(escape func(string) string) func(string) string {
+		return func(m string) string {
+			n := paramName(m)
+			v, ok := named[n]
+			if !ok && missing == "" {
+				missing = n
+			}
+			used[n] = true
+			return escape(v)
+		}
+	}
+	rurl := regParam.ReplaceAllStringFunc(rt.Path, fill(url.PathEscape))
+	rurl = regSplat.ReplaceAllStringFunc(rurl, fill(escapeSplat))
+	if missing != "" {
+		return nil, MissingUrlParam(rt.Name(), missing)
+	}
+	u := pathUrl(rurl)
+	if rt.Method == "GET" {
+		var keys []string
+		for k := range named {
+			if !used[k] {
+				keys = append(keys, k)
+			}
+		}
+		sort.Strings(keys)
+		var query []string
+		for _, k := range keys {
+			query = append(query, queryPair(k, named[k]))
+		}
+		u.RawQuery = strings.Join(query, "&")
+	}
+	return u, nil
+}
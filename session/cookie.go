@@ -4,6 +4,7 @@ import (
 	"crypto/aes"
 	"crypto/cipher"
 
+	"encoding/hex"
 	"encoding/json"
 	"net/http"
 	"net/url"
@@ -137,6 +138,17 @@ func (st *CookieSessionStore) Delete(key interface{}) error {
 	return nil
 }
 
+// List all keys in cookie session
+func (st *CookieSessionStore) Keys() []interface{} {
+	st.lock.RLock()
+	defer st.lock.RUnlock()
+	keys := make([]interface{}, 0, len(st.values))
+	for k := range st.values {
+		keys = append(keys, k)
+	}
+	return keys
+}
+
 // Clean all values in cookie session
 func (st *CookieSessionStore) Flush() error {
 	st.lock.Lock()
@@ -150,6 +162,14 @@ func (st *CookieSessionStore) SessionID() string {
 	return st.sid
 }
 
+// Issue a new id for this cookie session, retaining session values.
+func (st *CookieSessionStore) SessionRegenerate() error {
+	st.lock.Lock()
+	defer st.lock.Unlock()
+	st.sid = hex.EncodeToString(generateRandomKey(16))
+	return nil
+}
+
 // Write cookie session to http response cookie
 func (st *CookieSessionStore) SessionRelease(w http.ResponseWriter) {
 	str, err := encodeCookie(cookiepder.block,
@@ -1,9 +1,11 @@
 package session
 
-/*import (
-	"github.com/thrisp/flotilla/extension"
-	"github.com/thrisp/flotilla/session"
-	"github.com/thrisp/flotilla/state"
+import (
+	"fmt"
+	"sort"
+
+	"github.com/flxtilla/cxre/extension"
+	"github.com/flxtilla/cxre/xrr"
 )
 
 func mkFunction(k string, v interface{}) extension.Function {
@@ -11,32 +13,132 @@ func mkFunction(k string, v interface{}) extension.Function {
 }
 
 var sessionFns = []extension.Function{
+	mkFunction("clear_session", clearSession),
 	mkFunction("delete_session", deleteSession),
 	mkFunction("get_session", getSession),
+	mkFunction("get_session_bool", getSessionBool),
+	mkFunction("get_session_float", getSessionFloat),
+	mkFunction("get_session_int", getSessionInt),
+	mkFunction("get_session_int64", getSessionInt64),
+	mkFunction("get_session_string", getSessionString),
+	mkFunction("has_session", hasSession),
+	mkFunction("pop_session", popSession),
+	mkFunction("regenerate_session", regenerateSession),
 	mkFunction("session", returnSession),
+	mkFunction("session_keys", sessionKeys),
 	mkFunction("set_session", setSession),
 }
 
-var SessionFxtension extension.Fxtension = extension.New("Session_Fxtension", sessionFns...)
+// SessionExtension provides session functions to any extension.Extension
+// where the first inserted value is a SessionStore, e.g. a flotilla state.
+var SessionExtension extension.Extension = extension.New("Session_Extension", sessionFns...)
+
+var NotExpectedType = xrr.NewXrror("session value for key %s is %T, not %s").Out
 
-func deleteSession(s state.State, key string) error {
+func clearSession(s SessionStore) error {
+	return s.Flush()
+}
+
+func deleteSession(s SessionStore, key string) error {
 	return s.Delete(key)
 }
 
-func getSession(s state.State, key string) interface{} {
+func getSession(s SessionStore, key string) interface{} {
 	return s.Get(key)
 }
 
-func SessionStore(s state.State) SessionStore {
-	ss, _ := s.Call("session")
-	return ss.(session.SessionStore)
+func getSessionBool(s SessionStore, key string) (bool, error) {
+	v := s.Get(key)
+	if v == nil {
+		return false, nil
+	}
+	if ret, ok := v.(bool); ok {
+		return ret, nil
+	}
+	return false, NotExpectedType(key, v, "bool")
+}
+
+func getSessionFloat(s SessionStore, key string) (float64, error) {
+	v := s.Get(key)
+	if v == nil {
+		return 0.0, nil
+	}
+	if ret, ok := v.(float64); ok {
+		return ret, nil
+	}
+	return 0.0, NotExpectedType(key, v, "float64")
+}
+
+func getSessionInt(s SessionStore, key string) (int, error) {
+	v := s.Get(key)
+	if v == nil {
+		return 0, nil
+	}
+	if ret, ok := v.(int); ok {
+		return ret, nil
+	}
+	return 0, NotExpectedType(key, v, "int")
+}
+
+func getSessionInt64(s SessionStore, key string) (int64, error) {
+	v := s.Get(key)
+	if v == nil {
+		return 0, nil
+	}
+	switch ret := v.(type) {
+	case int64:
+		return ret, nil
+	case int:
+		return int64(ret), nil
+	}
+	return 0, NotExpectedType(key, v, "int64")
 }
 
-func returnSession(s state.State) SessionStore {
+func getSessionString(s SessionStore, key string) (string, error) {
+	v := s.Get(key)
+	if v == nil {
+		return "", nil
+	}
+	if ret, ok := v.(string); ok {
+		return ret, nil
+	}
+	return "", NotExpectedType(key, v, "string")
+}
+
+func hasSession(s SessionStore, key string) bool {
+	for _, k := range s.Keys() {
+		if k == key {
+			return true
+		}
+	}
+	return false
+}
+
+func popSession(s SessionStore, key string) (interface{}, error) {
+	v := s.Get(key)
+	if err := s.Delete(key); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+func regenerateSession(s SessionStore) error {
+	return s.SessionRegenerate()
+}
+
+func returnSession(s SessionStore) SessionStore {
 	return s
 }
 
-func setSession(s state.State, key string, value interface{}) error {
+func sessionKeys(s SessionStore) []string {
+	var ret []string
+	for _, k := range s.Keys() {
+		ret = append(ret, fmt.Sprintf("%v", k))
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+func setSession(s SessionStore, key string, value interface{}) error {
 	return s.Set(key, value)
 }
-*/
@@ -15,7 +15,9 @@ type SessionStore interface {
 	Set(key, value interface{}) error     //set session value
 	Get(key interface{}) interface{}      //get session value
 	Delete(key interface{}) error         //delete session value
+	Keys() []interface{}                  //list all session keys
 	SessionID() string                    //back current sessionID
+	SessionRegenerate() error             //issue a new sessionID retaining session data
 	SessionRelease(w http.ResponseWriter) //release the resource & save data to provider & return the data
 	Flush() error                         //delete all data
 }
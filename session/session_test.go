@@ -3,10 +3,16 @@ package session
 import (
 	"crypto/aes"
 	"encoding/json"
+	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
+
+	"github.com/flxtilla/cxre/extension"
+	"github.com/flxtilla/cxre/store"
 )
 
 type User struct {
@@ -126,3 +132,80 @@ func TestCookie(t *testing.T) {
 		}
 	}
 }
+
+func TestSessionsReload(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sessions")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	conf := filepath.Join(dir, "app.conf")
+	ioutil.WriteFile(conf, []byte("secret_key = flotillacookiehashkey\n[session]\ncookiename = first\nlifetime = 60\n"), 0644)
+	s := store.New()
+	if err := s.Load(conf); err != nil {
+		t.Fatal(err)
+	}
+	ss := NewSessions(s)
+	ss.Init()
+	if name := ss.Manager().config.CookieName; name != "first" {
+		t.Fatalf("session cookie name was %q, not first", name)
+	}
+
+	ioutil.WriteFile(conf, []byte("secret_key = flotillacookiehashkey\n[session]\ncookiename = second\nlifetime = 60\n"), 0644)
+	if err := s.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if name := ss.Manager().config.CookieName; name != "second" {
+		t.Errorf("session cookie name was %q after a reload, not second", name)
+	}
+
+	swapped := ss.Manager()
+	ss.SwapManager(swapped)
+	ioutil.WriteFile(conf, []byte("secret_key = flotillacookiehashkey\n[session]\ncookiename = third\nlifetime = 60\n"), 0644)
+	if err := s.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if ss.Manager() != swapped {
+		t.Error("a reload replaced a swapped session manager")
+	}
+}
+
+func TestSessionExtension(t *testing.T) {
+	st := &CookieSessionStore{sid: "test", values: make(map[interface{}]interface{})}
+	ext := extension.New("test_session_extension")
+	ext.Extend(SessionExtension)
+	ext.Insert(st)
+	if _, err := ext.Call("set_session", "agent", "Fox Mulder"); err != nil {
+		t.Fatal("set_session error,", err)
+	}
+	ext.MustCall("set_session", "badge", 1013)
+	if v := ext.CallString("get_session_string", "agent"); v != "Fox Mulder" {
+		t.Fatalf(`get_session_string returned %s, not "Fox Mulder"`, v)
+	}
+	if v := ext.CallInteger("get_session_int", "badge"); v != 1013 {
+		t.Fatalf(`get_session_int returned %d, not 1013`, v)
+	}
+	if _, err := ext.Call("get_session_int", "agent"); err == nil {
+		t.Fatal("get_session_int for a string value did not return an error")
+	}
+	if !ext.CallBoolean("has_session", "agent") {
+		t.Fatal(`has_session for "agent" returned false`)
+	}
+	keys := ext.MustCall("session_keys").([]string)
+	if strings.Join(keys, ",") != "agent,badge" {
+		t.Fatalf("session_keys returned %v, not [agent badge]", keys)
+	}
+	if v := ext.MustCall("pop_session", "agent"); v != "Fox Mulder" {
+		t.Fatalf(`pop_session returned %v, not "Fox Mulder"`, v)
+	}
+	if ext.CallBoolean("has_session", "agent") {
+		t.Fatal(`has_session for popped "agent" returned true`)
+	}
+	if _, err := ext.Call("regenerate_session"); err != nil || st.SessionID() == "test" {
+		t.Fatal("regenerate_session did not issue a new session id,", err)
+	}
+	ext.MustCall("clear_session")
+	if len(st.Keys()) != 0 {
+		t.Fatal("clear_session did not remove all session values")
+	}
+}
@@ -3,6 +3,7 @@ package session
 import (
 	"fmt"
 	"net/http"
+	"sync"
 
 	"github.com/flxtilla/cxre/store"
 )
@@ -15,25 +16,58 @@ type Sessions interface {
 }
 
 type sessions struct {
+	mu            sync.RWMutex
 	defaultConfig string
 	manager       *Manager
+	swapped       bool
 }
 
+var sessionSettings = []store.Setting{
+	{Name: "session_cookiename", Description: "Name of the session cookie.", Subsystem: "session"},
+	{Name: "session_lifetime", Type: store.TypeInt, Description: "Maximum age of a session in seconds.", Subsystem: "session"},
+	{Name: "secret_key", Description: "Key used to sign session cookies.", Subsystem: "session"},
+}
+
+// NewSessions returns Sessions configured by the session settings of the
+// store, rebuilding the default session manager when a reload changes them.
 func NewSessions(s store.Store) Sessions {
-	return &sessions{
+	store.MustRegister(s, sessionSettings...)
+	ss := &sessions{
 		defaultConfig: defaultSessionConfig(s),
 	}
+	reconfigure := func(store.Change) { ss.reconfigure(s) }
+	s.OnChange("session", reconfigure)
+	s.OnChange("secret_key", reconfigure)
+	return ss
 }
 
 func (s *sessions) Manager() *Manager {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
 	return s.manager
 }
 
 func (s *sessions) SwapManager(m *Manager) {
-	s.manager = m
+	s.mu.Lock()
+	s.manager, s.swapped = m, true
+	s.mu.Unlock()
 	s.Init()
 }
 
+// reconfigure rebuilds the default configuration from the store, replacing a
+// default session manager already in use; a swapped manager, or the current
+// manager where the new configuration is unusable, is retained.
+func (s *sessions) reconfigure(st store.Store) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.defaultConfig = defaultSessionConfig(st)
+	if s.manager != nil && !s.swapped {
+		if m, err := NewManager("cookie", s.defaultConfig); err == nil {
+			s.manager = m
+		}
+	}
+}
+
 func defaultSessionConfig(s store.Store) string {
 	cookieName := s.String("session_cookiename")
 	secretKey := s.String("secret_key")
@@ -52,14 +86,17 @@ func (s *sessions) defaultSessionManager() *Manager {
 
 // SessionInit intializes the SessionManager stored with the Env.
 func (s *sessions) Init() {
+	s.mu.Lock()
 	if s.manager == nil {
 		s.manager = s.defaultSessionManager()
 	}
-	go s.manager.GC()
+	m := s.manager
+	s.mu.Unlock()
+	go m.GC()
 }
 
 func (s *sessions) Start(w http.ResponseWriter, r *http.Request) (SessionStore, error) {
-	st, err := s.manager.SessionStart(w, r)
+	st, err := s.Manager().SessionStart(w, r)
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,33 @@
+package state
+
+import (
+	"net/http"
+
+	"github.com/flxtilla/cxre/engine"
+)
+
+// Detached returns a State made by mk for use outside of any request, e.g.
+// by functions called when rendering a template to an email. The State has a
+// GET request for the url and a ResponseWriter discarding anything written.
+func Detached(mk Make, url string) (State, error) {
+	rq, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	rs := engine.NewResult(http.StatusOK, nil, nil, false)
+	return mk(discard{make(http.Header)}, rq, rs, nil), nil
+}
+
+type discard struct {
+	header http.Header
+}
+
+func (d discard) Header() http.Header {
+	return d.header
+}
+
+func (d discard) Write(b []byte) (int, error) {
+	return len(b), nil
+}
+
+func (d discard) WriteHeader(int) {}
@@ -20,18 +20,35 @@ type ResponseWriter interface {
 	Size() int
 	Written() bool
 	WriteHeaderNow()
+	Before(func())
 }
 
 type responseWriter struct {
 	http.ResponseWriter
 	status int
 	size   int
+	before []func()
 }
 
 func (w *responseWriter) reset(writer http.ResponseWriter) {
 	w.ResponseWriter = writer
 	w.status = 200
 	w.size = NotWritten
+	w.before = nil
+}
+
+// Before adds a function to run once, immediately before the response header
+// is written, or when the state finishes running if nothing was written.
+func (w *responseWriter) Before(fn func()) {
+	w.before = append(w.before, fn)
+}
+
+func (w *responseWriter) runBefore() {
+	before := w.before
+	w.before = nil
+	for _, fn := range before {
+		fn()
+	}
 }
 
 func (w *responseWriter) WriteHeader(code int) {
@@ -42,6 +59,7 @@ func (w *responseWriter) WriteHeader(code int) {
 
 func (w *responseWriter) WriteHeaderNow() {
 	if !w.Written() {
+		w.runBefore()
 		w.size = 0
 		w.ResponseWriter.WriteHeader(w.status)
 	}
@@ -89,6 +89,7 @@ func New(ext extension.Extension, rs *engine.Result, lg log.Logger) *state {
 	s.Result = rs
 	s.Xrroror = rs.Xrroror
 	s.Extension = ext
+	s.Extend(session.SessionExtension, flash.FlashExtension)
 	s.RW = &s.rw
 	s.Flasher = flash.New()
 	return s
@@ -102,12 +103,13 @@ func (s *state) RWriter() ResponseWriter {
 	return s.RW
 }
 
+// release delivers flashed messages and releases the session; it is run
+// before the response header is written, so messages consumed by any manager
+// writing the response are not carried to the next request.
 func release(s State) {
 	w := s.RWriter()
-	if !w.Written() {
-		s.Out(s)
-		s.SessionRelease(w)
-	}
+	s.Deliver(w, s.Request(), s)
+	s.SessionRelease(w)
 }
 
 func LogFmt(s *state) string {
@@ -122,11 +124,12 @@ func LogFmt(s *state) string {
 }
 
 func (s *state) Run() {
-	s.Push(release)
+	s.RW.Before(func() { release(s) })
 	s.Next()
 	for _, fn := range s.deferred {
 		fn(s)
 	}
+	s.rw.runBefore()
 	s.PostProcess(s.request, s.RW.Status())
 	s.Logger.Printf(LogFmt(s))
 }
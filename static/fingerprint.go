@@ -0,0 +1,239 @@
+package static
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/flxtilla/cxre/asset"
+	"github.com/flxtilla/cxre/extension"
+	"github.com/flxtilla/cxre/log"
+	"github.com/flxtilla/cxre/state"
+	"github.com/flxtilla/cxre/xrr"
+)
+
+// Fingerprints is implemented by a Staticr serving files at fingerprinted
+// names, e.g. css/app.3f2a9c1b0d4e.css for css/app.css, from a manifest of
+// the files of the static directories and assets. Files remain served at
+// their original names.
+type Fingerprints interface {
+	Fingerprint() error
+	SetManifest(asset.Manifest)
+	Manifest() asset.Manifest
+	StaticName(string) string
+}
+
+var FingerprintError = xrr.NewXrror("Static files could not be fingerprinted, none are served at fingerprinted names: %s").Out
+
+// variant returns a boolean indicating if a file is a precompressed variant,
+// served in place of the file it compresses rather than fingerprinted.
+func variant(name string) bool {
+	for _, e := range encodings {
+		if strings.HasSuffix(name, e.suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// Fingerprint builds the manifest, from the file named by the static_manifest
+// setting, being a file or an asset written e.g. by the pack tool, or
+// otherwise by hashing every file of the static directories and assets. A
+// name held by several roots is fingerprinted from the one serving it. An
+// app may call Fingerprint at startup to fail on an unreadable manifest or
+// file, rather than have Manifest log the error on first use.
+func (st *staticr) Fingerprint() error {
+	if name := st.s.String("static_manifest"); name != "" {
+		m, err := st.readManifest(name)
+		if err != nil {
+			return err
+		}
+		st.setManifest(m, true)
+		return nil
+	}
+	m := make(asset.Manifest)
+	for _, dir := range st.s.List("static_directories") {
+		err := filepath.Walk(dir, func(p string, fi os.FileInfo, err error) error {
+			if err != nil || !fi.Mode().IsRegular() {
+				return err
+			}
+			rel, err := filepath.Rel(dir, p)
+			if err != nil {
+				return err
+			}
+			name := filepath.ToSlash(rel)
+			if _, ok := m[name]; ok || variant(name) || Fingerprinted(name) {
+				return nil
+			}
+			b, err := ioutil.ReadFile(p)
+			if err != nil {
+				return err
+			}
+			m.Add(name, b)
+			return nil
+		})
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	for _, fs := range st.a.ListAssetFS() {
+		for _, name := range fs.AssetNames() {
+			if _, ok := m[name]; ok || variant(name) || Fingerprinted(name) {
+				continue
+			}
+			b, err := fs.Asset(name)
+			if err != nil {
+				return err
+			}
+			m.Add(name, b)
+		}
+	}
+	st.setManifest(m, true)
+	return nil
+}
+
+func (st *staticr) readManifest(name string) (asset.Manifest, error) {
+	if b, err := ioutil.ReadFile(name); err == nil {
+		return asset.ReadManifest(bytes.NewReader(b), name)
+	}
+	b, err := st.a.GetAssetByte(name)
+	if err != nil {
+		return nil, err
+	}
+	return asset.ReadManifest(bytes.NewReader(b), name)
+}
+
+// SetManifest sets the manifest of fingerprinted names served.
+func (st *staticr) SetManifest(m asset.Manifest) {
+	st.setManifest(m, false)
+}
+
+func (st *staticr) setManifest(m asset.Manifest, built bool) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	st.manifest, st.originals, st.built = m, m.Original(), built
+}
+
+// discardManifest discards a manifest built by Fingerprint, to be built again
+// from the current settings; a manifest set by SetManifest is retained.
+func (st *staticr) discardManifest() {
+	st.build.Lock()
+	defer st.build.Unlock()
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if st.built {
+		st.manifest, st.originals, st.built = nil, nil, false
+	}
+}
+
+// Manifest returns the manifest of fingerprinted names served. Where the
+// static_fingerprint setting is true and there is none, e.g. as Fingerprint
+// was not called at startup, it is built by Fingerprint once, and any error
+// logged to standard error, leaving an empty manifest.
+func (st *staticr) Manifest() asset.Manifest {
+	return st.ensureManifest(nil)
+}
+
+// ensureManifest returns the manifest, building it once where there is none and the
+// static_fingerprint setting is true, logging any error to the logger.
+func (st *staticr) ensureManifest(lg log.StdLogger) asset.Manifest {
+	if m := st.current(); m != nil || !st.s.Bool("static_fingerprint") {
+		return m
+	}
+	st.build.Lock()
+	defer st.build.Unlock()
+	if m := st.current(); m != nil {
+		return m
+	}
+	if err := st.Fingerprint(); err != nil {
+		err = FingerprintError(err)
+		if lg != nil {
+			lg.Println(err)
+		} else {
+			fmt.Fprintln(os.Stderr, err)
+		}
+		st.setManifest(make(asset.Manifest), true)
+	}
+	return st.current()
+}
+
+func (st *staticr) current() asset.Manifest {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	return st.manifest
+}
+
+// StaticName returns the fingerprinted name of a file, or the name where
+// the file is not in the manifest.
+func (st *staticr) StaticName(name string) string {
+	clean, ok := Clean(name)
+	if !ok {
+		return name
+	}
+	if fp, ok := st.Manifest()[clean]; ok {
+		return fp
+	}
+	return name
+}
+
+// original returns the name of the file requested by a fingerprinted name,
+// or the requested name.
+func (st *staticr) original(s state.State, requested string) string {
+	st.ensureManifest(s)
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if name, ok := st.originals[requested]; ok {
+		return name
+	}
+	return requested
+}
+
+// StaticName returns the fingerprinted name of a file where the Staticr
+// fingerprints files, or the name otherwise.
+func (s *static) StaticName(name string) string {
+	if f, ok := s.Staticr.(Fingerprints); ok {
+		return f.StaticName(name)
+	}
+	return name
+}
+
+// Fingerprint builds the manifest where the Staticr fingerprints files and
+// the static_fingerprint setting is true, for an app to call at startup and
+// fail on any error; otherwise it does nothing.
+func (s *static) Fingerprint() error {
+	if f, ok := s.Staticr.(Fingerprints); ok && s.s.Bool("static_fingerprint") {
+		return f.Fingerprint()
+	}
+	return nil
+}
+
+// StaticExtension returns an extension providing the static_url function to a
+// state, returning the url for the fingerprinted name of a file from the
+// url_for_static function of the state.
+func (s *static) StaticExtension() extension.Extension {
+	return extension.New(
+		"Static_Extension",
+		extension.NewFunction("static_url", func(st state.State, name string) (string, error) {
+			u, err := st.Call("url_for_static", s.StaticName(name))
+			if err != nil {
+				return "", err
+			}
+			url, _ := u.(string)
+			return url, nil
+		}),
+	)
+}
+
+// StaticFunctions returns the static_url function for use as a template
+// function, returning the url made by urlFor, e.g. a blueprint UrlForStatic,
+// for the fingerprinted name of a file.
+func (s *static) StaticFunctions(urlFor func(string) (string, error)) map[string]interface{} {
+	return map[string]interface{}{
+		"static_url": func(name string) (string, error) {
+			return urlFor(s.StaticName(name))
+		},
+	}
+}
@@ -0,0 +1,268 @@
+package static
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/flxtilla/cxre/asset"
+	"github.com/flxtilla/cxre/state"
+)
+
+// CachePolicy is the Cache-Control of files served from a static directory.
+type CachePolicy struct {
+	MaxAge    time.Duration
+	Immutable bool
+	Private   bool
+	NoStore   bool
+}
+
+// String returns the Cache-Control header value of the policy. A policy
+// without a MaxAge requires clients to revalidate, using the ETag or
+// Last-Modified of the file.
+func (p CachePolicy) String() string {
+	if p.NoStore {
+		return "no-store"
+	}
+	scope := "public"
+	if p.Private {
+		scope = "private"
+	}
+	if p.MaxAge <= 0 {
+		return scope + ", no-cache"
+	}
+	cc := fmt.Sprintf("%s, max-age=%d", scope, int64(p.MaxAge/time.Second))
+	if p.Immutable {
+		cc += ", immutable"
+	}
+	return cc
+}
+
+const (
+	// AssetsPolicy names the CachePolicy of files served from assets.
+	AssetsPolicy = "assets"
+
+	// FingerprintedPolicy names the CachePolicy of fingerprinted files, e.g.
+	// app.3f2a9c1b0d4e.css, served from any static directory or assets.
+	FingerprintedPolicy = "fingerprinted"
+)
+
+// CachePolicies is implemented by a Staticr setting the CachePolicy of a
+// static directory, AssetsPolicy or FingerprintedPolicy.
+type CachePolicies interface {
+	SetCachePolicy(string, CachePolicy)
+}
+
+// SetCachePolicy sets the CachePolicy of files served from a static
+// directory, from assets with AssetsPolicy, or of fingerprinted files with
+// FingerprintedPolicy. Files without a policy use the static_max_age
+// setting.
+func (st *staticr) SetCachePolicy(root string, p CachePolicy) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if st.policies == nil {
+		st.policies = make(map[string]CachePolicy)
+	}
+	st.policies[filepath.Clean(root)] = p
+}
+
+var fingerprinted = regexp.MustCompile(fmt.Sprintf(`^[^/]+\.[0-9a-f]{%d}\.[^./]+$`, asset.FingerprintLength))
+
+// Fingerprinted returns a boolean indicating if a file name is of the form
+// given by asset.FingerprintName, e.g. app.3f2a9c1b0d4e.css, rather than
+// holding any hex digits, e.g. data-20161231.json.
+func Fingerprinted(name string) bool {
+	return fingerprinted.MatchString(path.Base(name))
+}
+
+// fingerprintedPolicy is the CachePolicy of files served at a name of the
+// manifest, without a FingerprintedPolicy.
+var fingerprintedPolicy = CachePolicy{MaxAge: 365 * 24 * time.Hour, Immutable: true}
+
+func (st *staticr) policy(root, name string) CachePolicy {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	_, manifested := st.originals[name]
+	if p, ok := st.policies[FingerprintedPolicy]; ok && (manifested || Fingerprinted(name)) {
+		return p
+	}
+	if manifested {
+		return fingerprintedPolicy
+	}
+	if p, ok := st.policies[root]; ok {
+		return p
+	}
+	maxAge, _ := st.s.LookupDuration("static_max_age")
+	return CachePolicy{MaxAge: maxAge}
+}
+
+// contentTypes are types of static files missing from some mime tables.
+var contentTypes = map[string]string{
+	".css":         "text/css; charset=utf-8",
+	".js":          "application/javascript",
+	".mjs":         "application/javascript",
+	".json":        "application/json",
+	".map":         "application/json",
+	".svg":         "image/svg+xml",
+	".wasm":        "application/wasm",
+	".webmanifest": "application/manifest+json",
+	".webp":        "image/webp",
+	".woff":        "font/woff",
+	".woff2":       "font/woff2",
+}
+
+// ContentType returns the type of a static file by its extension, or an
+// empty string where the type is not known and must be sniffed.
+func ContentType(name string) string {
+	ext := strings.ToLower(path.Ext(name))
+	if t, ok := contentTypes[ext]; ok {
+		return t
+	}
+	return mime.TypeByExtension(ext)
+}
+
+// encodings are the precompressed variants of a file served where accepted,
+// in order of preference, by Content-Encoding and file suffix.
+var encodings = []struct {
+	name, suffix string
+}{
+	{"br", ".br"},
+	{"gzip", ".gz"},
+}
+
+// accepts returns a boolean indicating if an Accept-Encoding header accepts
+// the encoding.
+func accepts(header, encoding string) bool {
+	for _, part := range strings.Split(header, ",") {
+		fields := strings.Split(strings.TrimSpace(part), ";")
+		if !strings.EqualFold(strings.TrimSpace(fields[0]), encoding) {
+			continue
+		}
+		for _, f := range fields[1:] {
+			if f = strings.TrimSpace(f); strings.HasPrefix(f, "q=") {
+				if q, err := strconv.ParseFloat(f[2:], 64); err == nil && q == 0 {
+					return false
+				}
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// file is a static file found for a request, from a static directory or
+// assets, opened with a suffix for a precompressed variant. A fallback file
+// is served in place of a file not found, and is always revalidated.
+type file struct {
+	root     string
+	name     string
+	open     func(string) (http.File, error)
+	fallback bool
+}
+
+func openRegular(p string) (http.File, error) {
+	f, err := os.Open(p)
+	if err != nil {
+		return nil, err
+	}
+	if fi, err := f.Stat(); err != nil || !fi.Mode().IsRegular() {
+		f.Close()
+		return nil, os.ErrNotExist
+	}
+	return f, nil
+}
+
+// etag returns an ETag for an opened file, from the size and modification
+// time of a file on disk, including assets of a directory layer, or the
+// content of a packed asset, which has no modification time.
+func (st *staticr) etag(f *file, suffix string, h http.File, fi os.FileInfo) string {
+	if !fi.ModTime().IsZero() {
+		return fmt.Sprintf(`"%x-%x%s"`, fi.Size(), fi.ModTime().UnixNano(), suffix)
+	}
+	key := f.name + suffix
+	st.mu.Lock()
+	tag, ok := st.etags[key]
+	st.mu.Unlock()
+	if ok {
+		return tag
+	}
+	hash := sha1.New()
+	if _, err := io.Copy(hash, h); err != nil {
+		return ""
+	}
+	if _, err := h.Seek(0, io.SeekStart); err != nil {
+		return ""
+	}
+	tag = fmt.Sprintf(`"%s%s"`, hex.EncodeToString(hash.Sum(nil))[:20], suffix)
+	st.mu.Lock()
+	if st.etags == nil {
+		st.etags = make(map[string]string)
+	}
+	st.etags[key] = tag
+	st.mu.Unlock()
+	return tag
+}
+
+// serve serves a static file with its content type and cache headers,
+// choosing a precompressed variant where one exists and is accepted, and
+// answering conditional and range requests. The file is closed after
+// serving.
+func (st *staticr) serve(s state.State, f *file) bool {
+	rq, w := s.Request(), s.RWriter()
+	var h http.File
+	var suffix string
+	varies := false
+	for _, e := range encodings {
+		v, err := f.open(e.suffix)
+		if err != nil {
+			continue
+		}
+		varies = true
+		if h == nil && accepts(rq.Header.Get("Accept-Encoding"), e.name) {
+			h, suffix = v, e.suffix
+			w.Header().Set("Content-Encoding", e.name)
+			continue
+		}
+		v.Close()
+	}
+	if h == nil {
+		var err error
+		if h, err = f.open(""); err != nil {
+			return false
+		}
+	}
+	defer h.Close()
+	fi, err := h.Stat()
+	if err != nil || fi.IsDir() {
+		w.Header().Del("Content-Encoding")
+		return false
+	}
+	if varies {
+		w.Header().Add("Vary", "Accept-Encoding")
+	}
+	if ct := ContentType(f.name); ct != "" {
+		w.Header().Set("Content-Type", ct)
+	} else if suffix != "" {
+		w.Header().Set("Content-Type", "application/octet-stream")
+	}
+	if tag := st.etag(f, suffix, h, fi); tag != "" {
+		w.Header().Set("ETag", tag)
+	}
+	cc := CachePolicy{}
+	if !f.fallback {
+		cc = st.policy(f.root, f.name)
+	}
+	w.Header().Set("Cache-Control", cc.String())
+	http.ServeContent(w, rq, f.name, fi.ModTime(), h)
+	return true
+}
@@ -0,0 +1,121 @@
+package static
+
+import (
+	"mime"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/flxtilla/cxre/state"
+	"github.com/flxtilla/cxre/xrr"
+)
+
+// SPA is the single-page application fallback of a static mount, serving a
+// document in place of any file not found beneath the mount for requests
+// accepting html, e.g. index.html for /app/users/1.
+type SPA struct {
+	// Index is the document served, a path relative to the static
+	// directories and assets.
+	Index string
+
+	// Exclude are the url path prefixes not falling back to the document,
+	// e.g. /app/api, that are not found as usual.
+	Exclude []string
+}
+
+// SPAs is implemented by a Staticr serving single-page application
+// fallbacks, by the url path a static route is mounted at, e.g. /app.
+type SPAs interface {
+	SetSPA(string, SPA)
+}
+
+var SPAUnavailable = xrr.NewXrror("Staticr %T does not serve single-page applications.").Out
+
+// mountPath returns the url path of a static mount, without a trailing
+// *filepath or slash.
+func mountPath(mount string) string {
+	mount = strings.TrimSuffix(mount, "*filepath")
+	return "/" + strings.Trim(mount, "/")
+}
+
+// SetSPA sets the single-page application fallback of the static mount at
+// the url path, with or without the trailing *filepath of the route.
+func (st *staticr) SetSPA(mount string, spa SPA) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if st.spas == nil {
+		st.spas = make(map[string]SPA)
+	}
+	st.spas[mountPath(mount)] = spa
+}
+
+// spaFor returns the fallback of the longest mount holding the path.
+func (st *staticr) spaFor(p string) (SPA, bool) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	var mounts []string
+	for m := range st.spas {
+		if m == "/" || p == m || strings.HasPrefix(p, m+"/") {
+			mounts = append(mounts, m)
+		}
+	}
+	if len(mounts) == 0 {
+		return SPA{}, false
+	}
+	sort.Slice(mounts, func(i, j int) bool {
+		return len(mounts[i]) > len(mounts[j])
+	})
+	return st.spas[mounts[0]], true
+}
+
+// acceptsHtml returns a boolean indicating if an Accept header explicitly
+// accepts html, as a browser navigating does, rather than any type.
+func acceptsHtml(header string) bool {
+	for _, part := range strings.Split(header, ",") {
+		t, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil || (t != "text/html" && t != "application/xhtml+xml") {
+			continue
+		}
+		if q, err := strconv.ParseFloat(params["q"], 64); err == nil && q == 0 {
+			continue
+		}
+		return true
+	}
+	return false
+}
+
+// fallback serves the single-page application document of the mount holding
+// a GET or HEAD request accepting html, returning a boolean indicating if
+// the document was served. The document is served to be revalidated, as it
+// names the fingerprinted files of a build.
+func (st *staticr) fallback(s state.State) bool {
+	rq := s.Request()
+	if rq.Method != http.MethodGet && rq.Method != http.MethodHead {
+		return false
+	}
+	spa, ok := st.spaFor(rq.URL.Path)
+	if !ok || spa.Index == "" || !acceptsHtml(rq.Header.Get("Accept")) {
+		return false
+	}
+	for _, x := range spa.Exclude {
+		if x = mountPath(x); rq.URL.Path == x || strings.HasPrefix(rq.URL.Path, x+"/") {
+			return false
+		}
+	}
+	index, ok := Clean(spa.Index)
+	if !ok {
+		return false
+	}
+	return st.serveFile(s, index, index, true)
+}
+
+// SetSPA sets the single-page application fallback of a static mount where
+// the Staticr serves them, returning an error otherwise.
+func (s *static) SetSPA(mount string, spa SPA) error {
+	if sp, ok := s.Staticr.(SPAs); ok {
+		sp.SetSPA(mount, spa)
+		return nil
+	}
+	return SPAUnavailable(s.Staticr)
+}
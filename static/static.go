@@ -3,26 +3,65 @@ package static
 import (
 	"net/http"
 	"os"
+	"path"
 	"path/filepath"
 	"strings"
+	"sync"
 
 	"github.com/flxtilla/cxre/asset"
+	"github.com/flxtilla/cxre/extension"
 	"github.com/flxtilla/cxre/state"
 	"github.com/flxtilla/cxre/store"
 )
 
+var staticSettings = []store.Setting{
+	{
+		Name:        "static_directories",
+		Type:        store.TypeList,
+		Description: "Directories static files are served from.",
+		Subsystem:   "static",
+	},
+	{
+		Name:        "static_max_age",
+		Type:        store.TypeDuration,
+		Default:     "0s",
+		Description: "Cache max-age of static files without a cache policy.",
+		Subsystem:   "static",
+	},
+	{
+		Name:        "static_fingerprint",
+		Type:        store.TypeBool,
+		Default:     "false",
+		Description: "Whether static files are also served at names fingerprinted with a hash of their content.",
+		Subsystem:   "static",
+	},
+	{
+		Name:        "static_manifest",
+		Description: "File or asset holding a manifest of fingerprinted names, e.g. written by the pack tool.",
+		Subsystem:   "static",
+	},
+}
+
 type Static interface {
 	Staticr
 	SwapStaticr(Staticr)
+	StaticName(string) string
+	Fingerprint() error
+	StaticExtension() extension.Extension
+	StaticFunctions(func(string) (string, error)) map[string]interface{}
+	SetSPA(string, SPA) error
 }
 
 type static struct {
 	Staticr
+	s store.Store
 }
 
 func New(s store.Store, a asset.Assets) Static {
+	store.MustRegister(s, staticSettings...)
 	return &static{
 		Staticr: defaultStaticr(s, a),
+		s:       s,
 	}
 }
 
@@ -37,15 +76,29 @@ type Staticr interface {
 }
 
 type staticr struct {
-	s store.Store
-	a asset.Assets
+	s         store.Store
+	a         asset.Assets
+	mu        sync.Mutex
+	build     sync.Mutex
+	key       string
+	index     map[string]string
+	policies  map[string]CachePolicy
+	etags     map[string]string
+	manifest  asset.Manifest
+	originals map[string]string
+	built     bool
+	spas      map[string]SPA
 }
 
+// defaultStaticr returns a Staticr of the static settings of the store,
+// discarding a manifest built from them when a reload changes them.
 func defaultStaticr(s store.Store, a asset.Assets) Staticr {
-	return &staticr{
+	st := &staticr{
 		s: s,
 		a: a,
 	}
+	s.OnChange("static", func(store.Change) { st.discardManifest() })
+	return st
 }
 
 func doAdd(s string, ss []string) []string {
@@ -75,56 +128,138 @@ func (st *staticr) StaticDirs(added ...string) []string {
 	return dirs
 }
 
-func (st *staticr) appStaticFile(requested string, s state.State) bool {
-	exists := false
-	for _, dir := range st.s.List("static_directories") {
-		filepath.Walk(dir, func(path string, _ os.FileInfo, _ error) (err error) {
-			if filepath.Base(path) == requested {
-				f, _ := os.Open(path)
-				serveStatic(s, f)
-				exists = true
+// Clean returns the slash separated path of a requested file relative to a
+// static root, and a boolean indicating if the request is for a file that
+// may be served, being false for a path leaving the root.
+func Clean(requested string) (string, bool) {
+	requested = strings.Replace(requested, "\\", "/", -1)
+	if strings.Contains(requested, "\x00") {
+		return "", false
+	}
+	for _, part := range strings.Split(requested, "/") {
+		if part == ".." {
+			return "", false
+		}
+	}
+	clean := strings.TrimPrefix(path.Clean("/"+requested), "/")
+	return clean, clean != ""
+}
+
+// resolve returns the path of the file for a cleaned request in the first
+// static directory holding it. Resolved files are cached for each set of
+// static directories, and a cached file is checked to still exist.
+func (st *staticr) resolve(requested string) (string, bool) {
+	dirs := st.s.List("static_directories")
+	key := strings.Join(dirs, ",")
+	st.mu.Lock()
+	if st.key != key {
+		st.key, st.index = key, make(map[string]string)
+	}
+	cached, ok := st.index[requested]
+	st.mu.Unlock()
+	if ok && isFile(cached) {
+		return cached, true
+	}
+	for _, dir := range dirs {
+		f := filepath.Join(dir, filepath.FromSlash(requested))
+		if rel, err := filepath.Rel(dir, f); err != nil || strings.HasPrefix(rel, "..") {
+			continue
+		}
+		if isFile(f) {
+			st.mu.Lock()
+			if st.key == key {
+				st.index[requested] = f
 			}
-			return err
-		})
+			st.mu.Unlock()
+			return f, true
+		}
 	}
-	return exists
+	if ok {
+		st.mu.Lock()
+		delete(st.index, requested)
+		st.mu.Unlock()
+	}
+	return "", false
+}
+
+func isFile(f string) bool {
+	fi, err := os.Stat(f)
+	return err == nil && fi.Mode().IsRegular()
 }
 
-func (st *staticr) appAssetFile(requested string, s state.State) bool {
-	exists := false
-	f, err := st.a.GetAsset(requested)
-	if err == nil {
-		serveStatic(s, f)
-		exists = true
+// staticFile returns the file of the first static directory holding the
+// name, served as the requested name.
+func (st *staticr) staticFile(requested, name string) (*file, bool) {
+	f, ok := st.resolve(name)
+	if !ok {
+		return nil, false
 	}
-	return exists
+	return &file{
+		root: filepath.Dir(strings.TrimSuffix(f, filepath.FromSlash(name))),
+		name: requested,
+		open: func(suffix string) (http.File, error) {
+			return openRegular(f + suffix)
+		},
+	}, true
 }
 
+// assetFile returns the asset with the name, served as the requested name.
+func (st *staticr) assetFile(requested, name string) *file {
+	return &file{
+		root: AssetsPolicy,
+		name: requested,
+		open: func(suffix string) (http.File, error) {
+			return st.a.GetAsset(name + suffix)
+		},
+	}
+}
+
+// serveFile serves the named file from the first static directory or asset
+// holding it, as the requested name, returning a boolean indicating if a
+// file was served.
+func (st *staticr) serveFile(s state.State, requested, name string, fallback bool) bool {
+	if f, ok := st.staticFile(requested, name); ok {
+		f.fallback = fallback
+		if st.serve(s, f) {
+			return true
+		}
+	}
+	f := st.assetFile(requested, name)
+	f.fallback = fallback
+	return st.serve(s, f)
+}
+
+// Exists serves the requested file, a path relative to the static
+// directories and assets or a fingerprinted name of one, from the first
+// static directory or asset holding it, returning a boolean indicating if a
+// file was served.
 func (st *staticr) Exists(s state.State, requested string) bool {
-	exists := st.appStaticFile(requested, s)
-	if !exists {
-		exists = st.appAssetFile(requested, s)
+	requested, ok := Clean(requested)
+	if !ok {
+		return false
 	}
-	return exists
+	return st.serveFile(s, requested, st.original(s, requested), false)
 }
 
+// StaticManage serves the requested file, or the single-page application
+// fallback of the mount, aborting with 404 where neither is served.
 func (st *staticr) StaticManage(s state.State) {
-	if !st.Exists(s, requestedFile(s)) {
+	if !st.Exists(s, requestedFile(s)) && !st.fallback(s) {
 		abortStatic(s)
 	} else {
 		s.Call("header_now")
 	}
 }
 
+// requestedFile returns the path of the requested file, being the filepath
+// of a static route, or the request path otherwise.
 func requestedFile(s state.State) string {
-	rq := s.Request()
-	return filepath.Base(rq.URL.Path)
+	if fp := s.Params().ByName("filepath"); fp != "" {
+		return fp
+	}
+	return s.Request().URL.Path
 }
 
 func abortStatic(s state.State) {
 	s.Call("abort", 404)
 }
-
-func serveStatic(s state.State, f http.File) {
-	s.Call("serve_file", f)
-}
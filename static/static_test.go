@@ -1,15 +1,26 @@
 package static_test
 
 import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
 	"net/http/httptest"
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/flxtilla/app"
+	"github.com/flxtilla/cxre/asset"
+	"github.com/flxtilla/cxre/engine"
+	"github.com/flxtilla/cxre/extension"
+	"github.com/flxtilla/cxre/log"
 	"github.com/flxtilla/cxre/state"
+	"github.com/flxtilla/cxre/static"
 	"github.com/flxtilla/cxre/static/resources"
+	"github.com/flxtilla/cxre/store"
 	"github.com/flxtilla/txst"
 )
 
@@ -117,3 +128,394 @@ func TestCustomStaticor(t *testing.T) {
 	)
 	txst.SimplePerformer(t, a, exp).Perform()
 }
+
+func TestClean(t *testing.T) {
+	for requested, expected := range map[string]string{
+		"/css/app.css":       "css/app.css",
+		"js//app.js":         "js/app.js",
+		"./css/./app.css":    "css/app.css",
+		`css\app.css`:        "css/app.css",
+		"../secret":          "",
+		"/css/../../secret":  "",
+		`..\secret`:          "",
+		"/":                  "",
+		"css/app.css\x00.js": "",
+	} {
+		clean, ok := static.Clean(requested)
+		if clean != expected || ok != (expected != "") {
+			t.Errorf(`Clean(%q) was %q, %t`, requested, clean, ok)
+		}
+	}
+}
+
+func TestCachePolicy(t *testing.T) {
+	for expected, p := range map[string]static.CachePolicy{
+		"public, no-cache":                    {},
+		"no-store":                            {MaxAge: time.Hour, NoStore: true},
+		"private, max-age=60":                 {MaxAge: time.Minute, Private: true},
+		"public, max-age=31536000, immutable": {MaxAge: 365 * 24 * time.Hour, Immutable: true},
+	} {
+		if cc := p.String(); cc != expected {
+			t.Errorf(`CachePolicy %+v was %q, expected %q`, p, cc, expected)
+		}
+	}
+	for name, expected := range map[string]bool{
+		"css/app.3f2a9c1b0d4e.css": true,
+		"css/app.3f2a9c1b.css":     false,
+		"js/app-3f2a9c1b0d4e.js":   false,
+		"css/app.3F2A9C1B0D4E.css": false,
+		"data-20161231.json":       false,
+		"css/app.css":              false,
+		"img/logo.cafe.png":        false,
+		"3f2a9c1b0d4e.d/app.css":   false,
+	} {
+		if static.Fingerprinted(name) != expected {
+			t.Errorf(`Fingerprinted(%q) was %t`, name, !expected)
+		}
+	}
+	for name, expected := range map[string]string{
+		"css/app.css":      "text/css; charset=utf-8",
+		"js/app.MJS":       "application/javascript",
+		"font/a.woff2":     "font/woff2",
+		"data/unknown.zzz": "",
+	} {
+		if ct := static.ContentType(name); ct != expected {
+			t.Errorf(`ContentType(%q) was %q, expected %q`, name, ct, expected)
+		}
+	}
+}
+
+func TestSetSPA(t *testing.T) {
+	st := static.New(store.New(), asset.New())
+	spa := static.SPA{Index: "index.html", Exclude: []string{"/app/api"}}
+	if err := st.SetSPA("/app/*filepath", spa); err != nil {
+		t.Errorf("SetSPA error: %s", err)
+	}
+	st.SwapStaticr(&testStatic{})
+	if err := st.SetSPA("/app", spa); err == nil {
+		t.Error("SetSPA of a Staticr without single-page applications did not return an error")
+	}
+}
+
+func TestFingerprintReload(t *testing.T) {
+	dir, err := ioutil.TempDir("", "static")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	for _, d := range []string{"one", "two"} {
+		os.MkdirAll(filepath.Join(dir, d), 0755)
+		ioutil.WriteFile(filepath.Join(dir, d, "app.css"), []byte("/* "+d+" */"), 0644)
+	}
+	conf := filepath.Join(dir, "app.conf")
+	write := func(d string) {
+		ioutil.WriteFile(conf, []byte(fmt.Sprintf("[static]\nfingerprint = true\ndirectories = %s\n", filepath.Join(dir, d))), 0644)
+	}
+	write("one")
+	s := store.New()
+	if err := s.Load(conf); err != nil {
+		t.Fatal(err)
+	}
+	st := static.New(s, asset.New())
+	one := st.StaticName("app.css")
+	if one != asset.FingerprintName("app.css", []byte("/* one */")) {
+		t.Fatalf("StaticName was %q", one)
+	}
+	write("two")
+	if err := s.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if two := st.StaticName("app.css"); two != asset.FingerprintName("app.css", []byte("/* two */")) {
+		t.Errorf("StaticName was %q after a reload changed the static directories", two)
+	}
+}
+
+func TestFingerprintError(t *testing.T) {
+	s := store.New()
+	s.Add("static_fingerprint", "true")
+	s.Add("static_manifest", "missing.json")
+	st := static.New(s, asset.New())
+	if err := st.Fingerprint(); err == nil {
+		t.Error("Fingerprint of a missing manifest did not return an error")
+	}
+	if name := st.StaticName("app.css"); name != "app.css" {
+		t.Errorf("StaticName after a failed fingerprint was %q", name)
+	}
+}
+
+// serveStatic serves a request for the file at the filepath of a static
+// route with the Static.
+func serveStatic(st static.Static, rq *http.Request, fp string) *httptest.ResponseRecorder {
+	ext := extension.New(
+		"Static_Test_Extension",
+		extension.NewFunction("abort", func(s state.State, code int) error {
+			s.RWriter().WriteHeader(code)
+			s.RWriter().WriteHeaderNow()
+			return nil
+		}),
+		extension.NewFunction("header_now", func(s state.State) error {
+			s.RWriter().WriteHeaderNow()
+			return nil
+		}),
+	)
+	rs := engine.NewResult(200, nil, engine.Params{{Key: "filepath", Value: fp}}, false)
+	s := state.New(ext, rs, log.New(ioutil.Discard, log.LInfo, log.DefaultNullFormatter()))
+	w := httptest.NewRecorder()
+	s.Reset(rq, w, nil)
+	st.StaticManage(s)
+	return w
+}
+
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	for name, content := range files {
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(p, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// trackingFS is an AssetFS counting the files it opens that remain open.
+type trackingFS struct {
+	asset.AssetFS
+	mu   sync.Mutex
+	open int
+}
+
+type trackedFile struct {
+	http.File
+	fs *trackingFS
+}
+
+func (f *trackedFile) Close() error {
+	f.fs.mu.Lock()
+	f.fs.open--
+	f.fs.mu.Unlock()
+	return f.File.Close()
+}
+
+func (fs *trackingFS) AssetHttp(name string) (http.File, error) {
+	f, err := fs.AssetFS.AssetHttp(name)
+	if err != nil {
+		return nil, err
+	}
+	fs.mu.Lock()
+	fs.open++
+	fs.mu.Unlock()
+	return &trackedFile{f, fs}, nil
+}
+
+func openDescriptors() int {
+	fds, err := ioutil.ReadDir("/proc/self/fd")
+	if err != nil {
+		return -1
+	}
+	return len(fds)
+}
+
+func TestStaticManage(t *testing.T) {
+	dir, err := ioutil.TempDir("", "static")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	one, two, packed := filepath.Join(dir, "one"), filepath.Join(dir, "two"), filepath.Join(dir, "packed")
+	writeFiles(t, one, map[string]string{"app.css": "one app", "css/site.css": "one site"})
+	writeFiles(t, two, map[string]string{"app.css": "two app", "js/app.css": "two js", "css/site.css": "two site"})
+	writeFiles(t, packed, map[string]string{"app.css": "packed app", "assets/only.css": "packed only"})
+	writeFiles(t, dir, map[string]string{"secret.txt": "secret"})
+
+	s := store.New()
+	s.Add("static_directories", one+","+two)
+	tracking := &trackingFS{AssetFS: asset.DirFS(packed)}
+	st := static.New(s, asset.New(tracking))
+
+	get := func(fp string) *httptest.ResponseRecorder {
+		return serveStatic(st, httptest.NewRequest("GET", "/static/file", nil), fp)
+	}
+	for fp, expected := range map[string]string{
+		"app.css":         "one app",
+		"css/site.css":    "one site",
+		"js/app.css":      "two js",
+		"/js//app.css":    "two js",
+		"assets/only.css": "packed only",
+	} {
+		if w := get(fp); w.Code != 200 || w.Body.String() != expected {
+			t.Errorf("%s served %d %q, not %q", fp, w.Code, w.Body.String(), expected)
+		}
+	}
+	os.Remove(filepath.Join(one, "app.css"))
+	if w := get("app.css"); w.Body.String() != "two app" {
+		t.Errorf("app.css removed from the first directory served %q", w.Body.String())
+	}
+	os.Remove(filepath.Join(two, "app.css"))
+	if w := get("app.css"); w.Body.String() != "packed app" {
+		t.Errorf("app.css removed from every directory served %q", w.Body.String())
+	}
+
+	for _, fp := range []string{"../secret.txt", "/../secret.txt", "css/../../secret.txt", `..\secret.txt`, "css/site.css\x00", "missing.css", "css"} {
+		if w := get(fp); w.Code != 404 || strings.Contains(w.Body.String(), "secret") {
+			t.Errorf("%q served %d %q", fp, w.Code, w.Body.String())
+		}
+	}
+
+	before := openDescriptors()
+	for n := 0; n < 50; n++ {
+		get("css/site.css")
+		get("assets/only.css")
+		get("missing.css")
+		serveStatic(st, httptest.NewRequest("HEAD", "/static/js/app.css", nil), "js/app.css")
+	}
+	if after := openDescriptors(); after > before {
+		t.Errorf("serving files left %d file descriptors open", after-before)
+	}
+	if tracking.open != 0 {
+		t.Errorf("serving assets left %d assets open", tracking.open)
+	}
+}
+
+func TestStaticVariants(t *testing.T) {
+	dir, err := ioutil.TempDir("", "static")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	files, packed := filepath.Join(dir, "files"), filepath.Join(dir, "packed")
+	writeFiles(t, files, map[string]string{
+		"app.js":    "plain javascript",
+		"app.js.gz": "gzip javascript",
+		"app.js.br": "brotli javascript",
+		"app.css":   "plain css",
+	})
+	writeFiles(t, packed, map[string]string{"data.json": `{"plain":true}`, "data.json.gz": "gzip json"})
+
+	s := store.New()
+	s.Add("static_directories", files)
+	st := static.New(s, asset.New(asset.DirFS(packed)))
+	get := func(fp string, header ...string) *httptest.ResponseRecorder {
+		rq := httptest.NewRequest("GET", "/static/"+fp, nil)
+		for n := 0; n+1 < len(header); n += 2 {
+			rq.Header.Set(header[n], header[n+1])
+		}
+		return serveStatic(st, rq, fp)
+	}
+
+	for _, c := range []struct {
+		fp, accept, body, encoding, suffix string
+	}{
+		{"app.js", "gzip, deflate, br", "brotli javascript", "br", `.br"`},
+		{"app.js", "gzip", "gzip javascript", "gzip", `.gz"`},
+		{"app.js", "br;q=0, gzip;q=0.5", "gzip javascript", "gzip", `.gz"`},
+		{"app.js", "", "plain javascript", "", ""},
+		{"app.js", "identity", "plain javascript", "", ""},
+		{"data.json", "br, gzip", "gzip json", "gzip", `.gz"`},
+		{"data.json", "br", `{"plain":true}`, "", ""},
+	} {
+		w := get(c.fp, "Accept-Encoding", c.accept)
+		tag := w.Header().Get("ETag")
+		if w.Code != 200 || w.Body.String() != c.body || w.Header().Get("Content-Encoding") != c.encoding {
+			t.Errorf("%s accepting %q served %d %q encoded %q", c.fp, c.accept, w.Code, w.Body.String(), w.Header().Get("Content-Encoding"))
+		}
+		if v := w.Header().Get("Vary"); v != "Accept-Encoding" {
+			t.Errorf("%s accepting %q varied by %q", c.fp, c.accept, v)
+		}
+		if tag == "" || (c.suffix != "" && !strings.HasSuffix(tag, c.suffix)) || (c.suffix == "" && (strings.HasSuffix(tag, `.gz"`) || strings.HasSuffix(tag, `.br"`))) {
+			t.Errorf("%s accepting %q had ETag %s", c.fp, c.accept, tag)
+		}
+		if ct := w.Header().Get("Content-Type"); ct != static.ContentType(c.fp) {
+			t.Errorf("%s accepting %q had Content-Type %q", c.fp, c.accept, ct)
+		}
+	}
+	if w := get("app.css", "Accept-Encoding", "gzip"); w.Header().Get("Vary") != "" || w.Header().Get("Content-Encoding") != "" {
+		t.Errorf("app.css without variants had Vary %q, Content-Encoding %q", w.Header().Get("Vary"), w.Header().Get("Content-Encoding"))
+	}
+
+	w := get("app.js", "Range", "bytes=0-4")
+	if w.Code != 206 || w.Body.String() != "plain" || w.Header().Get("Content-Range") != "bytes 0-4/16" {
+		t.Errorf("range request served %d %q, Content-Range %q", w.Code, w.Body.String(), w.Header().Get("Content-Range"))
+	}
+	w = get("data.json", "Range", "bytes=10-")
+	if w.Code != 206 || w.Body.String() != "rue}" {
+		t.Errorf("asset range request served %d %q", w.Code, w.Body.String())
+	}
+
+	plain := get("app.js").Header().Get("ETag")
+	gzipped := get("app.js", "Accept-Encoding", "gzip").Header().Get("ETag")
+	if plain == gzipped {
+		t.Fatalf("the gzip variant had the ETag of the file, %s", plain)
+	}
+	if w := get("app.js", "If-None-Match", plain); w.Code != 304 || w.Body.Len() != 0 {
+		t.Errorf("If-None-Match of the current ETag served %d %q", w.Code, w.Body.String())
+	}
+	if w := get("app.js", "Accept-Encoding", "gzip", "If-None-Match", gzipped); w.Code != 304 {
+		t.Errorf("If-None-Match of the variant ETag served %d", w.Code)
+	}
+	if w := get("app.js", "Accept-Encoding", "gzip", "If-None-Match", plain); w.Code != 200 || w.Body.String() != "gzip javascript" {
+		t.Errorf("If-None-Match of the file ETag for the variant served %d %q", w.Code, w.Body.String())
+	}
+	assetTag := get("data.json").Header().Get("ETag")
+	if w := get("data.json", "If-None-Match", assetTag); w.Code != 304 {
+		t.Errorf("If-None-Match of an asset ETag served %d", w.Code)
+	}
+
+	edited := filepath.Join(packed, "data.json")
+	ioutil.WriteFile(edited, []byte(`{"plain":false}`), 0644)
+	later := time.Now().Add(time.Minute)
+	os.Chtimes(edited, later, later)
+	w = get("data.json", "If-None-Match", assetTag)
+	if w.Code != 200 || w.Body.String() != `{"plain":false}` {
+		t.Errorf("If-None-Match of an edited asset's old ETag served %d %q", w.Code, w.Body.String())
+	}
+	if tag := w.Header().Get("ETag"); tag == "" || tag == assetTag {
+		t.Errorf("an edited asset kept the ETag %s", assetTag)
+	}
+}
+
+func TestSPAFallback(t *testing.T) {
+	dir, err := ioutil.TempDir("", "static")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	writeFiles(t, dir, map[string]string{"index.html": "<html>app</html>", "app.js": "javascript"})
+
+	s := store.New()
+	s.Add("static_directories", dir)
+	st := static.New(s, asset.New())
+	if err := st.SetSPA("/app/*filepath", static.SPA{Index: "index.html", Exclude: []string{"/app/api"}}); err != nil {
+		t.Fatal(err)
+	}
+	html := "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8"
+	for _, c := range []struct {
+		method, path, accept string
+		code                 int
+		body                 string
+	}{
+		{"GET", "/app/users/1/edit", html, 200, "<html>app</html>"},
+		{"HEAD", "/app/users/1", html, 200, ""},
+		{"GET", "/app", html, 200, "<html>app</html>"},
+		{"GET", "/app/app.js", html, 200, "javascript"},
+		{"GET", "/app/api/users", html, 404, ""},
+		{"GET", "/app/api", html, 404, ""},
+		{"GET", "/app/apiary", html, 200, "<html>app</html>"},
+		{"GET", "/app/users/1", "application/json", 404, ""},
+		{"GET", "/app/users/1", "*/*", 404, ""},
+		{"GET", "/app/users/1", "text/html;q=0, */*", 404, ""},
+		{"GET", "/app/users/1", "", 404, ""},
+		{"POST", "/app/users/1", html, 404, ""},
+		{"GET", "/other/users/1", html, 404, ""},
+	} {
+		rq := httptest.NewRequest(c.method, c.path, nil)
+		rq.Header.Set("Accept", c.accept)
+		w := serveStatic(st, rq, strings.TrimPrefix(strings.TrimPrefix(c.path, "/app"), "/"))
+		if w.Code != c.code || w.Body.String() != c.body {
+			t.Errorf("%s %s accepting %q served %d %q", c.method, c.path, c.accept, w.Code, w.Body.String())
+		}
+		if c.code == 200 && c.body != "javascript" && w.Header().Get("Cache-Control") != "public, no-cache" {
+			t.Errorf("%s %s fallback had Cache-Control %q", c.method, c.path, w.Header().Get("Cache-Control"))
+		}
+	}
+}
@@ -0,0 +1,210 @@
+package store
+
+import (
+	"fmt"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/flxtilla/cxre/xrr"
+)
+
+var (
+	NotAStructPointer = xrr.NewXrror("Store unmarshal requires a pointer to a struct, not %T.").Out
+	UnsupportedField  = xrr.NewXrror("Store unmarshal cannot bind field %s of type %s.").Out
+)
+
+var (
+	durationType = reflect.TypeOf(time.Duration(0))
+	timeType     = reflect.TypeOf(time.Time{})
+	sizeType     = reflect.TypeOf(Size(0))
+	urlType      = reflect.TypeOf(url.URL{})
+)
+
+// Unmarshal binds the items of the named section (or the root section for
+// an empty string) to the fields of the struct pointed to by v.
+//
+// Each field is bound to the item named by its `store` tag, or its upper cased
+// field name, and a `store:"-"` tag skips the field. A `default` tag provides
+// the value used when an item is missing. Nested struct fields are bound to
+// items prefixed by their name, e.g. field Pool in section database binds
+// database_pool_size to Pool.Size.
+//
+// Supported field types are strings, booleans, integers, floats,
+// time.Duration, time.Time, Size, url.URL (or a pointer to one), and slices
+// of these; list values are comma separated, and slices of slices separate
+// each list with a vertical bar, e.g. "a,b|c,d".
+func (s store) Unmarshal(section string, v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return NotAStructPointer(v)
+	}
+	return s.bindStruct(section, "", rv.Elem())
+}
+
+func fieldKey(f reflect.StructField) (string, bool) {
+	tag := f.Tag.Get("store")
+	if tag == "-" {
+		return "", false
+	}
+	if tag == "" {
+		return strings.ToUpper(f.Name), true
+	}
+	return strings.ToUpper(tag), true
+}
+
+func (s store) bindStruct(section, prefix string, rv reflect.Value) error {
+	rt := rv.Type()
+	for n := 0; n < rt.NumField(); n++ {
+		f := rt.Field(n)
+		if f.PkgPath != "" {
+			continue
+		}
+		fv := rv.Field(n)
+		if f.Anonymous && f.Type.Kind() == reflect.Struct {
+			if err := s.bindStruct(section, prefix, fv); err != nil {
+				return err
+			}
+			continue
+		}
+		key, ok := fieldKey(f)
+		if !ok {
+			continue
+		}
+		if prefix != "" {
+			key = prefix + "_" + key
+		}
+		if f.Type.Kind() == reflect.Struct && f.Type != timeType && f.Type != urlType {
+			if err := s.bindStruct(section, key, fv); err != nil {
+				return err
+			}
+			continue
+		}
+		name := itemName(section, key)
+		value, exists := f.Tag.Lookup("default")
+		shown := value
+		if i, ok := s.get(section, key); ok {
+			r, err := s.resolve(name, i)
+			if err != nil {
+				return err
+			}
+			value, shown, exists = r.Value, fmt.Sprintf("%v", r), true
+		}
+		if !exists {
+			continue
+		}
+		if err := setValue(fv, value); err != nil {
+			if _, unsupported := err.(unsupportedType); unsupported {
+				return UnsupportedField(f.Name, f.Type)
+			}
+			return InvalidItem(name, shown, f.Type.String())
+		}
+	}
+	return nil
+}
+
+func itemName(section, key string) string {
+	if section == "" {
+		return strings.ToLower(key)
+	}
+	return strings.ToLower(section + "_" + key)
+}
+
+type unsupportedType struct{ reflect.Type }
+
+func (u unsupportedType) Error() string {
+	return u.String()
+}
+
+func setValue(fv reflect.Value, value string) error {
+	switch fv.Type() {
+	case durationType:
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return err
+		}
+		fv.SetInt(int64(d))
+		return nil
+	case timeType:
+		t, err := parseTime(value)
+		if err != nil {
+			return err
+		}
+		fv.Set(reflect.ValueOf(t))
+		return nil
+	case sizeType:
+		sz, err := ParseSize(value)
+		if err != nil {
+			return err
+		}
+		fv.SetInt(int64(sz))
+		return nil
+	case urlType:
+		u, err := url.Parse(value)
+		if err != nil {
+			return err
+		}
+		fv.Set(reflect.ValueOf(*u))
+		return nil
+	}
+	switch fv.Kind() {
+	case reflect.String:
+		fv.SetString(value)
+	case reflect.Bool:
+		b, err := parseBool(value)
+		if err != nil {
+			return err
+		}
+		fv.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := strconv.ParseInt(value, 10, fv.Type().Bits())
+		if err != nil {
+			return err
+		}
+		fv.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		u, err := strconv.ParseUint(value, 10, fv.Type().Bits())
+		if err != nil {
+			return err
+		}
+		fv.SetUint(u)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(value, fv.Type().Bits())
+		if err != nil {
+			return err
+		}
+		fv.SetFloat(f)
+	case reflect.Ptr:
+		pv := reflect.New(fv.Type().Elem())
+		if err := setValue(pv.Elem(), value); err != nil {
+			return err
+		}
+		fv.Set(pv)
+	case reflect.Slice:
+		return setSlice(fv, value)
+	default:
+		return unsupportedType{fv.Type()}
+	}
+	return nil
+}
+
+func setSlice(fv reflect.Value, value string) error {
+	var parts []string
+	if fv.Type().Elem().Kind() == reflect.Slice {
+		parts = strings.Split(value, "|")
+	} else {
+		parts = splitList(value)
+	}
+	sl := reflect.MakeSlice(fv.Type(), 0, len(parts))
+	for _, p := range parts {
+		ev := reflect.New(fv.Type().Elem()).Elem()
+		if err := setValue(ev, strings.TrimSpace(p)); err != nil {
+			return err
+		}
+		sl = reflect.Append(sl, ev)
+	}
+	fv.Set(sl)
+	return nil
+}
@@ -0,0 +1,11 @@
+// Package store holds the configuration settings of a flotilla application,
+// loaded from INI, JSON, YAML and TOML files, environment variables and
+// flags.
+//
+// YAML and TOML are read by small parsers supporting only the subset of each
+// format commonly used for configuration. YAML anchors, aliases, tags and
+// multiple documents are not supported, and TOML date-times are kept as text
+// without being validated. Input outside the supported subset, or invalid in
+// either format, such as YAML indented with tabs or a redefined TOML key, is
+// rejected with an error naming the line.
+package store
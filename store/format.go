@@ -0,0 +1,140 @@
+package store
+
+import (
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/flxtilla/cxre/xrr"
+)
+
+// Format identifies a configuration format a Store may load.
+type Format string
+
+const (
+	INI  Format = "ini"
+	JSON Format = "json"
+	YAML Format = "yaml"
+	TOML Format = "toml"
+
+	// DotEnv is an env file of NAME=value lines.
+	DotEnv Format = "env"
+)
+
+// FormatFor returns the Format for a filename by extension, defaulting to
+// INI for any unrecognised extension.
+func FormatFor(filename string) Format {
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".json":
+		return JSON
+	case ".yaml", ".yml":
+		return YAML
+	case ".toml":
+		return TOML
+	case ".env":
+		return DotEnv
+	}
+	return INI
+}
+
+var UnknownFormat = xrr.NewXrror("Store cannot load unknown configuration format %q.").Out
+
+// object is an ordered mapping parsed from a nested configuration format.
+// Values are *object, []interface{}, or a string holding the scalar text.
+type object struct {
+	keys   []string
+	values map[string]interface{}
+}
+
+func newObject() *object {
+	return &object{values: make(map[string]interface{})}
+}
+
+func (o *object) set(key string, value interface{}) {
+	if _, exists := o.values[key]; !exists {
+		o.keys = append(o.keys, key)
+	}
+	o.values[key] = value
+}
+
+func (o *object) get(key string) (interface{}, bool) {
+	v, ok := o.values[key]
+	return v, ok
+}
+
+// loadObject maps a parsed object onto the store: scalar values at the top
+// level are added to the root section, objects at the top level become
+// sections, and deeper objects are flattened into keys joined by an
+// underscore, e.g. {"database": {"pool": {"size": 10}}} adds database_pool_size.
+func (s store) loadObject(o *object) {
+	for _, k := range o.keys {
+		switch v := o.values[k].(type) {
+		case *object:
+			s.flatten(k, "", v)
+		default:
+			s.flattenValue("", k, v)
+		}
+	}
+}
+
+func joinKey(prefix, key string) string {
+	if prefix == "" {
+		return key
+	}
+	return prefix + "_" + key
+}
+
+func (s store) flatten(section, prefix string, o *object) {
+	for _, k := range o.keys {
+		s.flattenValue(section, joinKey(prefix, k), o.values[k])
+	}
+}
+
+func (s store) flattenValue(section, key string, v interface{}) {
+	switch vv := v.(type) {
+	case *object:
+		s.flatten(section, key, vv)
+	case []interface{}:
+		if hasObjects(vv) {
+			for i, item := range vv {
+				s.flattenValue(section, joinKey(key, strconv.Itoa(i)), item)
+			}
+			return
+		}
+		s.add(section, key, listValue(vv))
+	default:
+		s.add(section, key, scalarValue(v))
+	}
+}
+
+func hasObjects(l []interface{}) bool {
+	for _, item := range l {
+		if _, ok := item.(*object); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// listValue joins a list of scalars with commas, and a list of lists with a
+// vertical bar, matching the list values bound by Unmarshal.
+func listValue(l []interface{}) string {
+	var parts []string
+	sep := ","
+	for _, item := range l {
+		if sub, ok := item.([]interface{}); ok {
+			sep = "|"
+			parts = append(parts, listValue(sub))
+			continue
+		}
+		parts = append(parts, scalarValue(item))
+	}
+	return strings.Join(parts, sep)
+}
+
+func scalarValue(v interface{}) string {
+	if s, ok := v.(string); ok {
+		return s
+	}
+	return ""
+}
@@ -0,0 +1,134 @@
+package store
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/flxtilla/cxre/xrr"
+)
+
+var (
+	UnresolvedReference = xrr.NewXrror("Store item %s references %s, which does not exist.").Out
+	ReferenceCycle      = xrr.NewXrror("Store item %s has a reference cycle: %s.").Out
+	UnreadableSecret    = xrr.NewXrror("Store item %s secret could not be read: %s").Out
+)
+
+const (
+	// SecretPrefix marks a value as a reference to a file holding a secret,
+	// e.g. file:/run/secrets/key, read each time the item is resolved.
+	SecretPrefix = "file:"
+
+	// Redacted replaces secret values in dumps and formatted items.
+	Redacted = "[redacted]"
+)
+
+var reference = regexp.MustCompile(`\$\$\{|\$\{([^}]*)\}`)
+
+// resolve returns the item with references expanded and any secret read.
+// Values may reference other items as ${section_key} and environment
+// variables as ${ENV:NAME}, with $${ escaping a literal ${. An item whose
+// value references a secret is itself secret.
+func (s store) resolve(name string, i StoreItem) (*storeItem, error) {
+	var src Source
+	if si, ok := i.(*storeItem); ok {
+		src = si.source
+	}
+	v, secret, err := s.expand(name, i.String(), []string{strings.ToLower(name)})
+	if err != nil {
+		return nil, err
+	}
+	return &storeItem{Key: strings.ToUpper(name), Value: v, source: src, secret: secret}, nil
+}
+
+func (s store) expand(name, value string, seen []string) (string, bool, error) {
+	if strings.HasPrefix(value, SecretPrefix) {
+		b, err := ioutil.ReadFile(strings.TrimPrefix(value, SecretPrefix))
+		if err != nil {
+			return "", true, UnreadableSecret(name, err)
+		}
+		return strings.TrimRight(string(b), "\r\n"), true, nil
+	}
+	var secret bool
+	var err error
+	expanded := reference.ReplaceAllStringFunc(value, func(m string) string {
+		if err != nil {
+			return ""
+		}
+		if m == "$${" {
+			return "${"
+		}
+		ref := m[2 : len(m)-1]
+		if strings.HasPrefix(ref, "ENV:") {
+			v, ok := os.LookupEnv(ref[4:])
+			if !ok {
+				err = UnresolvedReference(name, ref)
+			}
+			return v
+		}
+		ref = strings.ToLower(ref)
+		path := append(seen[:len(seen):len(seen)], ref)
+		for _, n := range seen {
+			if n == ref {
+				err = ReferenceCycle(name, strings.Join(path, " -> "))
+				return ""
+			}
+		}
+		i, ok := s.lookup(ref)
+		if !ok {
+			err = UnresolvedReference(name, ref)
+			return ""
+		}
+		v, sec, e := s.expand(ref, i.String(), path)
+		if e != nil {
+			err = e
+		}
+		secret = secret || sec
+		return v
+	})
+	return expanded, secret, err
+}
+
+func (s store) resolveKey(key string) (*storeItem, error) {
+	i, ok := s.lookup(key)
+	if !ok {
+		return nil, MissingItem(key)
+	}
+	return s.resolve(key, i)
+}
+
+// Dump writes every item, sorted by name, with the source of its value and
+// with secret values redacted. References are expanded where they resolve.
+func (s store) Dump(w io.Writer) error {
+	var names []string
+	items := make(map[string]StoreItem)
+	for section, keys := range s {
+		for key, i := range keys {
+			n := itemName(section, key)
+			names = append(names, n)
+			items[n] = i
+		}
+	}
+	sort.Strings(names)
+	for _, n := range names {
+		i := items[n]
+		value := i.String()
+		if r, err := s.resolve(n, i); err == nil {
+			value = fmt.Sprintf("%v", r)
+		} else if strings.HasPrefix(value, SecretPrefix) {
+			value = Redacted
+		}
+		var src Source
+		if si, ok := i.(*storeItem); ok {
+			src = si.source
+		}
+		if _, err := fmt.Fprintf(w, "%s = %q # %s\n", n, value, src); err != nil {
+			return err
+		}
+	}
+	return nil
+}
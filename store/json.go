@@ -0,0 +1,75 @@
+package store
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"github.com/flxtilla/cxre/xrr"
+)
+
+var StoreJSONError = xrr.NewXrror("Store configuration parsing: invalid JSON in '%s': %s.").Out
+
+func (s store) parseJSON(b []byte, name string) error {
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	v, err := decodeJSON(dec)
+	if err != nil {
+		return StoreJSONError(name, err)
+	}
+	o, ok := v.(*object)
+	if !ok {
+		return StoreJSONError(name, "top level value must be an object")
+	}
+	s.loadObject(o)
+	return nil
+}
+
+// decodeJSON decodes the next JSON value from the decoder, retaining object
+// key order.
+func decodeJSON(dec *json.Decoder) (interface{}, error) {
+	t, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	switch tv := t.(type) {
+	case json.Delim:
+		switch tv {
+		case '{':
+			o := newObject()
+			for dec.More() {
+				kt, err := dec.Token()
+				if err != nil {
+					return nil, err
+				}
+				v, err := decodeJSON(dec)
+				if err != nil {
+					return nil, err
+				}
+				o.set(fmt.Sprintf("%v", kt), v)
+			}
+			_, err := dec.Token()
+			return o, err
+		case '[':
+			l := make([]interface{}, 0)
+			for dec.More() {
+				v, err := decodeJSON(dec)
+				if err != nil {
+					return nil, err
+				}
+				l = append(l, v)
+			}
+			_, err := dec.Token()
+			return l, err
+		}
+	case json.Number:
+		return tv.String(), nil
+	case string:
+		return tv, nil
+	case bool:
+		return fmt.Sprintf("%t", tv), nil
+	case nil:
+		return "", nil
+	}
+	return nil, fmt.Errorf("unexpected token %v", t)
+}
@@ -0,0 +1,215 @@
+package store
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Layer is a source of store items. Items from a higher layer take
+// precedence over items from a lower layer, regardless of the order in which
+// they are loaded; within a layer the item loaded last is used.
+type Layer int
+
+const (
+	Defaults Layer = iota
+	Files
+	Profiles
+	Env
+	Flags
+	Set
+)
+
+var layerNames = map[Layer]string{
+	Defaults: "defaults",
+	Files:    "file",
+	Profiles: "profile",
+	Env:      "env",
+	Flags:    "flag",
+	Set:      "set",
+}
+
+func (l Layer) String() string {
+	if n, ok := layerNames[l]; ok {
+		return n
+	}
+	return fmt.Sprintf("layer(%d)", int(l))
+}
+
+// Source records the layer, and the name within the layer (a filename,
+// environment variable or flag), that provided a store item.
+type Source struct {
+	Layer Layer
+	Name  string
+}
+
+func (s Source) String() string {
+	if s.Name == "" {
+		return s.Layer.String()
+	}
+	return fmt.Sprintf("%s %s", s.Layer, s.Name)
+}
+
+// Layerer loads store items from layered sources.
+type Layerer interface {
+	Default(string, string)
+	LoadProfile(string, string) error
+	LoadEnv(string)
+	LoadFlags(*flag.FlagSet)
+	Source(string) (Source, bool)
+}
+
+// Default adds an item to the defaults layer, used only when no other layer
+// provides the item.
+func (s store) Default(key, value string) {
+	section, k := s.locate(key)
+	s.set(section, k, value, Source{Layer: Defaults})
+}
+
+// ProfileFilename returns the profile specific filename for a filename, e.g.
+// app.production.conf for app.conf and the production profile.
+func ProfileFilename(filename, profile string) string {
+	ext := filepath.Ext(filename)
+	return fmt.Sprintf("%s.%s%s", strings.TrimSuffix(filename, ext), profile, ext)
+}
+
+// LoadProfile loads filename to the files layer and, if it exists, the
+// profile specific file for the named profile to the profiles layer.
+func (s store) LoadProfile(filename, profile string) error {
+	if err := s.Load(filename); err != nil {
+		return err
+	}
+	if profile == "" {
+		return nil
+	}
+	pf := ProfileFilename(filename, profile)
+	if _, err := os.Stat(pf); os.IsNotExist(err) {
+		return nil
+	}
+	return s.loadFrom(pf, Profiles)
+}
+
+// LoadEnv adds environment variables beginning with the prefix and an
+// underscore to the env layer, e.g. with the prefix APP the variable
+// APP_SESSION_LIFETIME provides session_lifetime.
+func (s store) LoadEnv(prefix string) {
+	p := strings.ToUpper(prefix) + "_"
+	for _, kv := range os.Environ() {
+		i := strings.Index(kv, "=")
+		if i < 0 {
+			continue
+		}
+		name := kv[:i]
+		if !strings.HasPrefix(strings.ToUpper(name), p) || len(name) == len(p) {
+			continue
+		}
+		section, key := s.locate(name[len(p):])
+		s.set(section, key, kv[i+1:], Source{Env, name})
+	}
+}
+
+// LoadFlags adds flags set on the command line to the flags layer, and the
+// default values of flags not set to the defaults layer. Flag names map to
+// keys with dashes and dots as underscores, e.g. -session-lifetime provides
+// session_lifetime. The flag set must have been parsed.
+func (s store) LoadFlags(fs *flag.FlagSet) {
+	set := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) {
+		set[f.Name] = true
+	})
+	fs.VisitAll(func(f *flag.Flag) {
+		section, key := s.locate(flagKey(f.Name))
+		if set[f.Name] {
+			s.set(section, key, f.Value.String(), Source{Flags, "-" + f.Name})
+			return
+		}
+		if f.DefValue != "" {
+			s.set(section, key, f.DefValue, Source{Defaults, "-" + f.Name})
+		}
+	})
+}
+
+func flagKey(name string) string {
+	return strings.NewReplacer("-", "_", ".", "_").Replace(name)
+}
+
+// Source returns the source of the item for a key, and a boolean indicating
+// if the item exists.
+func (s store) Source(key string) (Source, bool) {
+	if i, ok := s.lookup(key); ok {
+		if si, ok := i.(*storeItem); ok {
+			return si.source, true
+		}
+	}
+	return Source{}, false
+}
+
+// locate returns the section and key for an item key, being that of an
+// existing item when there is one, and otherwise split at the first
+// underscore.
+func (s store) locate(key string) (string, string) {
+	base := strings.Split(key, "_")
+	for n := 1; n < len(base); n++ {
+		section, k := strings.Join(base[:n], "_"), strings.Join(base[n:], "_")
+		if _, ok := s.get(section, k); ok {
+			return section, k
+		}
+	}
+	if _, ok := s.get("", key); ok || len(base) == 1 {
+		return "", key
+	}
+	return base[0], strings.Join(base[1:], "_")
+}
+
+// set adds the item unless an item from a higher layer exists.
+func (s store) set(section, key, value string, src Source) {
+	s.put(section, key, &storeItem{Value: value, source: src})
+}
+
+// put adds an item unless an item from a higher layer exists. An item
+// replacing an existing item keeps its position, and its comments when the
+// replacing item has none.
+func (s store) put(section, key string, i *storeItem) {
+	sec, seckey := strings.ToUpper(section), strings.ToUpper(key)
+	i.Key = seckey
+	if e, ok := s.get(sec, seckey); ok {
+		if ei, ok := e.(*storeItem); ok {
+			if ei.source.Layer > i.source.Layer {
+				return
+			}
+			i.seq = ei.seq
+			if len(i.comments) == 0 {
+				i.comments = ei.comments
+			}
+			if len(i.header) == 0 {
+				i.header = ei.header
+			}
+		}
+	} else {
+		i.seq = s.size() + 1
+	}
+	if _, ok := s[sec]; !ok {
+		s[sec] = make(map[string]StoreItem)
+	}
+	s[sec][seckey] = i
+}
+
+func (s store) size() int {
+	var n int
+	for _, items := range s {
+		n += len(items)
+	}
+	return n
+}
+
+// merge sets every item of from, a store parsed from a single source, in the
+// order parsed.
+func (s store) merge(from store, src Source) {
+	for _, e := range from.ordered() {
+		i := *e.item
+		i.source = src
+		s.put(e.section, e.key, &i)
+	}
+}
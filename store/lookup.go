@@ -0,0 +1,234 @@
+package store
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/flxtilla/cxre/xrr"
+)
+
+// Lookupr provides typed store lookups returning an error that distinguishes a
+// missing item (IsMissing) from an item with an invalid value (IsInvalid).
+type Lookupr interface {
+	Lookup(string) (string, error)
+	LookupBool(string) (bool, error)
+	LookupFloat(string) (float64, error)
+	LookupInt(string) (int, error)
+	LookupInt64(string) (int64, error)
+	LookupList(string) ([]string, error)
+	LookupDuration(string) (time.Duration, error)
+	LookupTime(string) (time.Time, error)
+	LookupSize(string) (Size, error)
+	LookupURL(string) (*url.URL, error)
+}
+
+const (
+	missingItem = "Store item %s does not exist."
+	invalidItem = "Store item %s value %q is not a valid %s."
+)
+
+// MissingItem returns an error for a store item that does not exist.
+func MissingItem(key string) *xrr.Xrror {
+	return xrr.NewXrror(missingItem, key)
+}
+
+// InvalidItem returns an error for a store item value that cannot be
+// converted to the named type.
+func InvalidItem(key, value, typ string) *xrr.Xrror {
+	return xrr.NewXrror(invalidItem, key, value, typ)
+}
+
+// IsMissing returns a boolean indicating if the error is for a store item
+// that does not exist.
+func IsMissing(err error) bool {
+	x, ok := err.(*xrr.Xrror)
+	return ok && x.Err == missingItem
+}
+
+// IsInvalid returns a boolean indicating if the error is for a store item
+// value that could not be converted.
+func IsInvalid(err error) bool {
+	x, ok := err.(*xrr.Xrror)
+	return ok && x.Err == invalidItem
+}
+
+func (s store) Lookup(key string) (string, error) {
+	i, err := s.resolveKey(key)
+	if err != nil {
+		return "", err
+	}
+	return i.String(), nil
+}
+
+func (s store) lookupParsed(key, typ string, parse func(string) (interface{}, error)) (interface{}, error) {
+	i, err := s.resolveKey(key)
+	if err != nil {
+		return nil, err
+	}
+	ret, err := parse(i.Value)
+	if err != nil {
+		return nil, InvalidItem(key, fmt.Sprintf("%v", i), typ)
+	}
+	return ret, nil
+}
+
+func (s store) LookupBool(key string) (bool, error) {
+	v, err := s.lookupParsed(key, "bool", func(v string) (interface{}, error) { return parseBool(v) })
+	if err != nil {
+		return false, err
+	}
+	return v.(bool), nil
+}
+
+func (s store) LookupFloat(key string) (float64, error) {
+	v, err := s.lookupParsed(key, "float", func(v string) (interface{}, error) { return strconv.ParseFloat(v, 64) })
+	if err != nil {
+		return 0.0, err
+	}
+	return v.(float64), nil
+}
+
+func (s store) LookupInt(key string) (int, error) {
+	v, err := s.lookupParsed(key, "int", func(v string) (interface{}, error) { return strconv.Atoi(v) })
+	if err != nil {
+		return 0, err
+	}
+	return v.(int), nil
+}
+
+func (s store) LookupInt64(key string) (int64, error) {
+	v, err := s.lookupParsed(key, "int64", func(v string) (interface{}, error) { return strconv.ParseInt(v, 10, 64) })
+	if err != nil {
+		return 0, err
+	}
+	return v.(int64), nil
+}
+
+func (s store) LookupList(key string) ([]string, error) {
+	v, err := s.Lookup(key)
+	if err != nil {
+		return nil, err
+	}
+	return splitList(v), nil
+}
+
+func (s store) LookupDuration(key string) (time.Duration, error) {
+	v, err := s.lookupParsed(key, "duration", func(v string) (interface{}, error) { return time.ParseDuration(v) })
+	if err != nil {
+		return 0, err
+	}
+	return v.(time.Duration), nil
+}
+
+func (s store) LookupTime(key string) (time.Time, error) {
+	v, err := s.lookupParsed(key, "time", func(v string) (interface{}, error) { return parseTime(v) })
+	if err != nil {
+		return time.Time{}, err
+	}
+	return v.(time.Time), nil
+}
+
+func (s store) LookupSize(key string) (Size, error) {
+	v, err := s.lookupParsed(key, "size", func(v string) (interface{}, error) { return ParseSize(v) })
+	if err != nil {
+		return 0, err
+	}
+	return v.(Size), nil
+}
+
+func (s store) LookupURL(key string) (*url.URL, error) {
+	v, err := s.lookupParsed(key, "url", func(v string) (interface{}, error) { return url.Parse(v) })
+	if err != nil {
+		return nil, err
+	}
+	return v.(*url.URL), nil
+}
+
+var invalidBool = xrr.NewXrror("invalid boolean value")
+
+func parseBool(v string) (bool, error) {
+	if value, ok := boolString[strings.ToLower(v)]; ok {
+		return value, nil
+	}
+	return false, invalidBool
+}
+
+func splitList(v string) []string {
+	if v == "" {
+		return nil
+	}
+	return strings.Split(v, ",")
+}
+
+var timeLayouts = []string{
+	time.RFC3339Nano,
+	time.RFC3339,
+	"2006-01-02 15:04:05",
+	"2006-01-02T15:04:05",
+	"2006-01-02",
+}
+
+var invalidTime = xrr.NewXrror("invalid time value")
+
+func parseTime(v string) (time.Time, error) {
+	for _, layout := range timeLayouts {
+		if t, err := time.Parse(layout, v); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, invalidTime
+}
+
+// Size is a number of bytes, parsed from values such as "512", "64KB",
+// "10MB" or "1.5GiB". Units are powers of 1024.
+type Size int64
+
+const (
+	Byte     Size = 1
+	Kilobyte      = 1024 * Byte
+	Megabyte      = 1024 * Kilobyte
+	Gigabyte      = 1024 * Megabyte
+	Terabyte      = 1024 * Gigabyte
+)
+
+var sizeUnits = map[string]Size{
+	"":    Byte,
+	"B":   Byte,
+	"K":   Kilobyte,
+	"KB":  Kilobyte,
+	"KIB": Kilobyte,
+	"M":   Megabyte,
+	"MB":  Megabyte,
+	"MIB": Megabyte,
+	"G":   Gigabyte,
+	"GB":  Gigabyte,
+	"GIB": Gigabyte,
+	"T":   Terabyte,
+	"TB":  Terabyte,
+	"TIB": Terabyte,
+}
+
+var invalidSize = xrr.NewXrror("invalid size value")
+
+// ParseSize parses a size value with an optional unit suffix.
+func ParseSize(v string) (Size, error) {
+	v = strings.TrimSpace(v)
+	i := strings.IndexFunc(v, func(r rune) bool {
+		return !(r >= '0' && r <= '9') && r != '.'
+	})
+	if i == -1 {
+		i = len(v)
+	}
+	unit, ok := sizeUnits[strings.ToUpper(strings.TrimSpace(v[i:]))]
+	if !ok || i == 0 {
+		return 0, invalidSize
+	}
+	n, err := strconv.ParseFloat(v[:i], 64)
+	if err != nil {
+		return 0, invalidSize
+	}
+	return Size(n * float64(unit)), nil
+}
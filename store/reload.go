@@ -0,0 +1,516 @@
+package store
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/flxtilla/cxre/log"
+	"github.com/flxtilla/cxre/xrr"
+)
+
+var InvalidReload = xrr.NewXrror("Store reload rejected, running configuration retained: %s").Out
+
+// Reloader reloads a store from its sources, publishing changes to items.
+// Subsystems subscribe to the changes to their settings: sessions rebuild
+// their default manager, static files discard a built manifest, and a logger
+// followed with FollowLogLevel takes a changed log_level.
+type Reloader interface {
+	Reload() error
+	Watch(time.Duration) func()
+	OnChange(string, func(Change))
+	Validate(func(Store) error)
+	SetLogger(log.StdLogger)
+}
+
+// ChangeKind describes how an item changed.
+type ChangeKind int
+
+const (
+	ItemAdded ChangeKind = iota
+	ItemChanged
+	ItemRemoved
+)
+
+func (k ChangeKind) String() string {
+	switch k {
+	case ItemAdded:
+		return "added"
+	case ItemRemoved:
+		return "removed"
+	}
+	return "changed"
+}
+
+// Change describes a change to an item by a load or reload. Old and New are
+// the resolved values of the item, with references expanded and secret
+// values redacted, so an item changes when a value it references changes.
+type Change struct {
+	Kind    ChangeKind
+	Section string
+	Key     string
+	Old     string
+	New     string
+}
+
+// Name returns the name of the changed item, e.g. session_lifetime.
+func (c Change) Name() string {
+	return itemName(c.Section, c.Key)
+}
+
+type subscriber struct {
+	name string
+	fn   func(Change)
+}
+
+func (sb subscriber) matches(c Change) bool {
+	return sb.name == "" || sb.name == strings.ToLower(c.Section) || sb.name == c.Name()
+}
+
+// live is a Store whose items are replaced atomically: every load builds a
+// new store from the current one, and every reload builds a new store by
+// replaying each load in order.
+type live struct {
+	mu         sync.RWMutex
+	reloading  sync.Mutex
+	current    store
+	values     values
+	loads      []func(store) error
+	files      []string
+	validators []func(Store) error
+	settings   []Setting
+	subs       []subscriber
+	logger     log.StdLogger
+}
+
+func newLive() *live {
+	return &live{current: make(store), values: make(values)}
+}
+
+func (l *live) snapshot() store {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.current
+}
+
+func (s store) clone() store {
+	c := make(store, len(s))
+	for section, items := range s {
+		ci := make(map[string]StoreItem, len(items))
+		for k, i := range items {
+			ci[k] = i
+		}
+		c[section] = ci
+	}
+	return c
+}
+
+// apply applies a load to a copy of the current store, recording the load
+// and any files it reads, and publishes the resulting changes.
+func (l *live) apply(load func(store) error, files ...string) error {
+	l.mu.Lock()
+	next := l.current.clone()
+	if err := load(next); err != nil {
+		l.mu.Unlock()
+		return err
+	}
+	prev, vs := l.values, next.values()
+	l.current, l.values = next, vs
+	l.loads = append(l.loads, load)
+	l.files = append(l.files, files...)
+	subs := l.subs
+	l.mu.Unlock()
+	publish(subs, diff(prev, vs))
+	return nil
+}
+
+// Reload rebuilds the store from its sources, rereading files, environment
+// variables and flags. The rebuilt store replaces the current store only if
+// every load succeeds, Check finds no problems other than unknown keys
+// (which are logged), and every validator accepts it; otherwise the error is
+// logged and returned, and the current store is retained.
+func (l *live) Reload() error {
+	l.reloading.Lock()
+	defer l.reloading.Unlock()
+	for {
+		l.mu.RLock()
+		loads, validators, settings := l.loads, l.validators, l.settings
+		l.mu.RUnlock()
+
+		next, err := l.rebuild(loads, validators, settings)
+		if err != nil {
+			err = InvalidReload(err)
+			l.log(err)
+			return err
+		}
+
+		l.mu.Lock()
+		if len(l.loads) != len(loads) {
+			// a load was applied while rebuilding, e.g. by Add; rebuild
+			// with it rather than dropping it from the store
+			l.mu.Unlock()
+			continue
+		}
+		prev, vs := l.values, next.values()
+		l.current, l.values = next, vs
+		subs := l.subs
+		l.mu.Unlock()
+		publish(subs, diff(prev, vs))
+		return nil
+	}
+}
+
+// rebuild builds a store by replaying the loads, returning an error where a
+// load fails, Check finds problems other than unknown keys, or a validator
+// rejects it.
+func (l *live) rebuild(loads []func(store) error, validators []func(Store) error, settings []Setting) (store, error) {
+	next := make(store)
+	for _, load := range loads {
+		if err := load(next); err != nil {
+			return nil, err
+		}
+	}
+	var rejected Problems
+	for _, p := range next.problems(settings) {
+		if p.Kind == UnknownKey {
+			l.log(p)
+			continue
+		}
+		rejected = append(rejected, p)
+	}
+	if len(rejected) > 0 {
+		return nil, rejected
+	}
+	candidate := &live{current: next}
+	for _, v := range validators {
+		if err := v(candidate); err != nil {
+			return nil, err
+		}
+	}
+	return next, nil
+}
+
+// Watch polls the files loaded by the store at the interval, reloading the
+// store when any is created, modified or removed, until the returned
+// function is called.
+func (l *live) Watch(interval time.Duration) func() {
+	stop := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		seen := l.stat()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				now := l.stat()
+				if now != seen {
+					seen = now
+					l.Reload()
+				}
+			}
+		}
+	}()
+	var once sync.Once
+	return func() {
+		once.Do(func() { close(stop) })
+	}
+}
+
+// stat returns a signature of the existence, size and modification time of
+// each file loaded by the store.
+func (l *live) stat() string {
+	l.mu.RLock()
+	files := l.files
+	l.mu.RUnlock()
+	var sig []string
+	for _, f := range files {
+		fi, err := os.Stat(f)
+		if err != nil {
+			sig = append(sig, f+":-")
+			continue
+		}
+		sig = append(sig, fmt.Sprintf("%s:%d:%d", f, fi.Size(), fi.ModTime().UnixNano()))
+	}
+	return strings.Join(sig, "\n")
+}
+
+// OnChange registers a function called for each change to an item, where
+// name is the item name, its section, or an empty string for every item.
+func (l *live) OnChange(name string, fn func(Change)) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.subs = append(l.subs, subscriber{strings.ToLower(name), fn})
+}
+
+// Validate registers a function that must accept a reloaded store before it
+// replaces the current store.
+func (l *live) Validate(fn func(Store) error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.validators = append(l.validators, fn)
+}
+
+// SetLogger sets the logger rejected reloads are logged to, by default
+// standard error.
+func (l *live) SetLogger(lg log.StdLogger) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.logger = lg
+}
+
+var logLevelSetting = Setting{
+	Name:        "log_level",
+	Description: "Level of the application log.",
+	Choices:     []string{"panic", "fatal", "error", "warn", "info", "debug"},
+	Subsystem:   "log",
+}
+
+// FollowLogLevel registers the log_level setting and sets the level of the
+// logger from it, now and whenever a reload changes it. An unset or
+// unrecognized level leaves the logger level unchanged.
+func FollowLogLevel(s Store, lg log.Logger) error {
+	if err := s.Register(logLevelSetting); err != nil {
+		return err
+	}
+	set := func(value string) {
+		if lv := log.StringToLevel(value); lv != log.LUnrecognized {
+			lg.SetLevel(lv)
+		}
+	}
+	set(s.String("log_level"))
+	s.OnChange("log_level", func(Change) { set(s.String("log_level")) })
+	return nil
+}
+
+func (l *live) log(err interface{}) {
+	l.mu.RLock()
+	lg := l.logger
+	l.mu.RUnlock()
+	if lg == nil {
+		fmt.Fprintln(os.Stderr, err)
+		return
+	}
+	lg.Println(err)
+}
+
+// value is the resolved value of an item, as compared between loads.
+type value struct {
+	v      string
+	secret bool
+}
+
+func (v value) String() string {
+	if v.secret {
+		return Redacted
+	}
+	return v.v
+}
+
+// values holds the resolved value of every item of a store, by section and
+// key, as the store was when it was loaded: a reference to an environment
+// variable or a secret file is resolved once per load.
+type values map[string]map[string]value
+
+// values resolves every item of the store, taking the unresolved value of an
+// item that cannot be resolved.
+func (s store) values() values {
+	vs := make(values, len(s))
+	for section, items := range s {
+		vi := make(map[string]value, len(items))
+		for key, i := range items {
+			v := value{v: i.String()}
+			if r, err := s.resolve(itemName(section, key), i); err == nil {
+				v = value{r.Value, r.secret}
+			}
+			vi[key] = v
+		}
+		vs[section] = vi
+	}
+	return vs
+}
+
+func diff(prev, next values) []Change {
+	var changes []Change
+	change := func(k ChangeKind, section, key, old, new string) {
+		changes = append(changes, Change{k, strings.ToLower(section), strings.ToLower(key), old, new})
+	}
+	for section, items := range next {
+		for key, v := range items {
+			if p, ok := prev[section][key]; !ok {
+				change(ItemAdded, section, key, "", v.String())
+			} else if p.v != v.v {
+				change(ItemChanged, section, key, p.String(), v.String())
+			}
+		}
+	}
+	for section, items := range prev {
+		for key, v := range items {
+			if _, ok := next[section][key]; !ok {
+				change(ItemRemoved, section, key, v.String(), "")
+			}
+		}
+	}
+	sort.Slice(changes, func(a, b int) bool {
+		return changes[a].Name() < changes[b].Name()
+	})
+	return changes
+}
+
+func publish(subs []subscriber, changes []Change) {
+	for _, c := range changes {
+		for _, sb := range subs {
+			if sb.matches(c) {
+				sb.fn(c)
+			}
+		}
+	}
+}
+
+func (l *live) Load(filename string) error {
+	return l.apply(func(s store) error { return s.Load(filename) }, filename)
+}
+
+func (l *live) LoadByte(b []byte, name string, format ...Format) error {
+	b = append([]byte(nil), b...)
+	return l.apply(func(s store) error { return s.LoadByte(b, name, format...) })
+}
+
+func (l *live) LoadProfile(filename, profile string) error {
+	files := []string{filename}
+	if profile != "" {
+		files = append(files, ProfileFilename(filename, profile))
+	}
+	return l.apply(func(s store) error { return s.LoadProfile(filename, profile) }, files...)
+}
+
+func (l *live) LoadEnv(prefix string) {
+	l.apply(func(s store) error {
+		s.LoadEnv(prefix)
+		return nil
+	})
+}
+
+func (l *live) LoadFlags(fs *flag.FlagSet) {
+	l.apply(func(s store) error {
+		s.LoadFlags(fs)
+		return nil
+	})
+}
+
+func (l *live) Default(key, value string) {
+	l.apply(func(s store) error {
+		s.Default(key, value)
+		return nil
+	})
+}
+
+func (l *live) Add(key, value string) {
+	l.apply(func(s store) error {
+		s.Add(key, value)
+		return nil
+	})
+}
+
+func (l *live) Source(key string) (Source, bool) {
+	return l.snapshot().Source(key)
+}
+
+func (l *live) Unmarshal(section string, v interface{}) error {
+	return l.snapshot().Unmarshal(section, v)
+}
+
+func (l *live) Dump(w io.Writer) error {
+	return l.snapshot().Dump(w)
+}
+
+func (l *live) String(key string) string {
+	return l.snapshot().String(key)
+}
+
+func (l *live) List(key string) []string {
+	return l.snapshot().List(key)
+}
+
+func (l *live) Bool(key string) bool {
+	return l.snapshot().Bool(key)
+}
+
+func (l *live) Float(key string) float64 {
+	return l.snapshot().Float(key)
+}
+
+func (l *live) Int(key string) int {
+	return l.snapshot().Int(key)
+}
+
+func (l *live) Int64(key string) int64 {
+	return l.snapshot().Int64(key)
+}
+
+func (l *live) Lookup(key string) (string, error) {
+	return l.snapshot().Lookup(key)
+}
+
+func (l *live) LookupBool(key string) (bool, error) {
+	return l.snapshot().LookupBool(key)
+}
+
+func (l *live) LookupFloat(key string) (float64, error) {
+	return l.snapshot().LookupFloat(key)
+}
+
+func (l *live) LookupInt(key string) (int, error) {
+	return l.snapshot().LookupInt(key)
+}
+
+func (l *live) LookupInt64(key string) (int64, error) {
+	return l.snapshot().LookupInt64(key)
+}
+
+func (l *live) LookupList(key string) ([]string, error) {
+	return l.snapshot().LookupList(key)
+}
+
+func (l *live) LookupDuration(key string) (time.Duration, error) {
+	return l.snapshot().LookupDuration(key)
+}
+
+func (l *live) LookupTime(key string) (time.Time, error) {
+	return l.snapshot().LookupTime(key)
+}
+
+func (l *live) LookupSize(key string) (Size, error) {
+	return l.snapshot().LookupSize(key)
+}
+
+func (l *live) LookupURL(key string) (*url.URL, error) {
+	return l.snapshot().LookupURL(key)
+}
+
+func (l *live) Sections() []string {
+	return l.snapshot().Sections()
+}
+
+func (l *live) Keys(section string) []string {
+	return l.snapshot().Keys(section)
+}
+
+func (l *live) Each(fn func(string, StoreItem)) {
+	l.snapshot().Each(fn)
+}
+
+func (l *live) Write(w io.Writer, f Format) error {
+	return l.snapshot().Write(w, f)
+}
+
+func (l *live) WriteEnv(w io.Writer, prefix string) error {
+	return l.snapshot().WriteEnv(w, prefix)
+}
@@ -0,0 +1,402 @@
+package store
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/flxtilla/cxre/xrr"
+)
+
+var ConflictingSetting = xrr.NewXrror("Store setting %s is already registered as %s by %s.").Out
+
+// SettingType is the type of value a setting expects.
+type SettingType string
+
+const (
+	TypeString   SettingType = "string"
+	TypeBool     SettingType = "bool"
+	TypeInt      SettingType = "int"
+	TypeFloat    SettingType = "float"
+	TypeDuration SettingType = "duration"
+	TypeSize     SettingType = "size"
+	TypeTime     SettingType = "time"
+	TypeURL      SettingType = "url"
+	TypeList     SettingType = "list"
+)
+
+// Setting describes an item a subsystem consumes. A Name ending in an
+// asterisk describes every item with that prefix, e.g. servers_*.
+type Setting struct {
+	Name        string
+	Type        SettingType
+	Default     string
+	Description string
+	Required    bool
+	Choices     []string
+	Check       func(string) error
+	Subsystem   string
+}
+
+func (st Setting) matches(name string) bool {
+	if strings.HasSuffix(st.Name, "*") {
+		return strings.HasPrefix(name, strings.TrimSuffix(st.Name, "*"))
+	}
+	return name == st.Name
+}
+
+// ProblemKind classifies a problem found when checking a store.
+type ProblemKind int
+
+const (
+	UnknownKey ProblemKind = iota
+	TypeMismatch
+	MissingRequired
+	ConstraintViolation
+)
+
+var problemKinds = map[ProblemKind]string{
+	UnknownKey:          "unknown key",
+	TypeMismatch:        "type mismatch",
+	MissingRequired:     "missing required value",
+	ConstraintViolation: "constraint violation",
+}
+
+func (k ProblemKind) String() string {
+	return problemKinds[k]
+}
+
+// Problem is a problem with a single item found when checking a store.
+type Problem struct {
+	Name    string
+	Kind    ProblemKind
+	Message string
+}
+
+func (p Problem) String() string {
+	return fmt.Sprintf("%s: %s: %s", p.Name, p.Kind, p.Message)
+}
+
+// Problems is every problem found when checking a store.
+type Problems []Problem
+
+func (ps Problems) Error() string {
+	var lines []string
+	for _, p := range ps {
+		lines = append(lines, p.String())
+	}
+	return fmt.Sprintf("Store configuration has %d problem(s):\n\t%s", len(ps), strings.Join(lines, "\n\t"))
+}
+
+// ReferenceStyle selects the layout of a settings reference.
+type ReferenceStyle int
+
+const (
+	TextReference ReferenceStyle = iota
+	MarkdownReference
+)
+
+// Schemar registers the settings consumed from a store, checks a store
+// against them, and documents them.
+type Schemar interface {
+	Register(...Setting) error
+	Settings() []Setting
+	Check() error
+	Reference(io.Writer, ReferenceStyle) error
+}
+
+// MustRegister registers settings, panicking where a setting conflicts with
+// a registered setting, as a subsystem registering its settings does.
+func MustRegister(s Schemar, settings ...Setting) {
+	if err := s.Register(settings...); err != nil {
+		panic(err.Error())
+	}
+}
+
+// Register registers settings, adding any setting default to the defaults
+// layer. A setting may be registered again, e.g. by a second instance of a
+// subsystem, only with the same type.
+func (l *live) Register(settings ...Setting) error {
+	for _, st := range settings {
+		st.Name = strings.ToLower(st.Name)
+		if st.Type == "" {
+			st.Type = TypeString
+		}
+		l.mu.Lock()
+		var conflict error
+		replaced := false
+		for n, existing := range l.settings {
+			if existing.Name != st.Name {
+				continue
+			}
+			if existing.Type != st.Type {
+				conflict = ConflictingSetting(st.Name, existing.Type, existing.Subsystem)
+			} else {
+				l.settings[n], replaced = st, true
+			}
+		}
+		if conflict == nil && !replaced {
+			l.settings = append(l.settings, st)
+		}
+		l.mu.Unlock()
+		if conflict != nil {
+			return conflict
+		}
+		if st.Default != "" && !strings.HasSuffix(st.Name, "*") {
+			l.Default(st.Name, st.Default)
+		}
+	}
+	return nil
+}
+
+// Settings returns the registered settings sorted by name.
+func (l *live) Settings() []Setting {
+	l.mu.RLock()
+	settings := append([]Setting(nil), l.settings...)
+	l.mu.RUnlock()
+	sort.Slice(settings, func(a, b int) bool {
+		return settings[a].Name < settings[b].Name
+	})
+	return settings
+}
+
+// Check checks the store against the registered settings, returning
+// Problems for unknown items, values that are not of the setting type or
+// violate its constraints, and required settings without a value. An item
+// not matching any setting is unknown where it shares the prefix of a
+// setting, e.g. session_, or is within a few edits of a setting, so that the
+// items of an application are not reported. Check returns nil when no
+// settings are registered.
+func (l *live) Check() error {
+	l.mu.RLock()
+	settings := l.settings
+	l.mu.RUnlock()
+	if problems := l.snapshot().problems(settings); len(problems) > 0 {
+		return problems
+	}
+	return nil
+}
+
+func (s store) problems(settings []Setting) Problems {
+	if len(settings) == 0 {
+		return nil
+	}
+	var problems Problems
+	for _, name := range s.names() {
+		if !known(settings, name) {
+			msg := "not a registered setting"
+			suggestion := suggest(settings, name)
+			if suggestion != "" {
+				msg = fmt.Sprintf("%s, did you mean %s?", msg, suggestion)
+			} else if !claimed(settings, name) {
+				continue
+			}
+			problems = append(problems, Problem{name, UnknownKey, msg})
+		}
+	}
+	for _, st := range settings {
+		if strings.HasSuffix(st.Name, "*") {
+			continue
+		}
+		i, err := s.resolveKey(st.Name)
+		if IsMissing(err) || (err == nil && i.Value == "") {
+			if st.Required {
+				problems = append(problems, Problem{st.Name, MissingRequired, "a value is required"})
+			}
+			continue
+		}
+		if err != nil {
+			problems = append(problems, Problem{st.Name, TypeMismatch, err.Error()})
+			continue
+		}
+		if err := s.checkType(st); err != nil {
+			problems = append(problems, Problem{st.Name, TypeMismatch, err.Error()})
+			continue
+		}
+		if len(st.Choices) > 0 && !contains(st.Choices, i.Value) {
+			problems = append(problems, Problem{st.Name, ConstraintViolation, fmt.Sprintf("%v is not one of %s", i, strings.Join(st.Choices, ", "))})
+			continue
+		}
+		if st.Check != nil {
+			if err := st.Check(i.Value); err != nil {
+				problems = append(problems, Problem{st.Name, ConstraintViolation, err.Error()})
+			}
+		}
+	}
+	sort.SliceStable(problems, func(a, b int) bool {
+		return problems[a].Name < problems[b].Name
+	})
+	return problems
+}
+
+func (s store) checkType(st Setting) error {
+	var err error
+	switch st.Type {
+	case TypeBool:
+		_, err = s.LookupBool(st.Name)
+	case TypeInt:
+		_, err = s.LookupInt64(st.Name)
+	case TypeFloat:
+		_, err = s.LookupFloat(st.Name)
+	case TypeDuration:
+		_, err = s.LookupDuration(st.Name)
+	case TypeSize:
+		_, err = s.LookupSize(st.Name)
+	case TypeTime:
+		_, err = s.LookupTime(st.Name)
+	case TypeURL:
+		_, err = s.LookupURL(st.Name)
+	}
+	return err
+}
+
+// names returns the names of every item, sorted.
+func (s store) names() []string {
+	var names []string
+	for section, items := range s {
+		for key := range items {
+			names = append(names, itemName(section, key))
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+func known(settings []Setting, name string) bool {
+	for _, st := range settings {
+		if st.matches(name) {
+			return true
+		}
+	}
+	return false
+}
+
+// claimed returns whether a name shares the prefix of a registered setting,
+// the part of its name before the first underscore.
+func claimed(settings []Setting, name string) bool {
+	prefix := strings.SplitN(name, "_", 2)[0]
+	for _, st := range settings {
+		if p := strings.SplitN(st.Name, "_", 2); len(p) == 2 && p[0] == prefix {
+			return true
+		}
+	}
+	return false
+}
+
+func contains(ss []string, s string) bool {
+	for _, x := range ss {
+		if x == s {
+			return true
+		}
+	}
+	return false
+}
+
+// suggest returns the registered setting closest to an unknown name, if it
+// is within a few edits.
+func suggest(settings []Setting, name string) string {
+	best, distance := "", 3
+	for _, st := range settings {
+		if strings.HasSuffix(st.Name, "*") {
+			continue
+		}
+		if d := editDistance(name, st.Name); d < distance {
+			best, distance = st.Name, d
+		}
+	}
+	return best
+}
+
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur := make([]int, len(b)+1)
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min3(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev = cur
+	}
+	return prev[len(b)]
+}
+
+func min3(a, b, c int) int {
+	if b < a {
+		a = b
+	}
+	if c < a {
+		a = c
+	}
+	return a
+}
+
+// Reference writes a reference of every registered setting as text or
+// Markdown.
+func (l *live) Reference(w io.Writer, style ReferenceStyle) error {
+	var b bytes.Buffer
+	settings := l.Settings()
+	if style == MarkdownReference {
+		b.WriteString("| Setting | Type | Default | Required | Description |\n")
+		b.WriteString("| --- | --- | --- | --- | --- |\n")
+		for _, st := range settings {
+			fmt.Fprintf(&b, "| `%s` | %s | %s | %s | %s |\n",
+				st.Name, st.Type, markdownCode(st.Default), yesNo(st.Required), markdownCell(describe(st)))
+		}
+	} else {
+		for n, st := range settings {
+			if n > 0 {
+				b.WriteString("\n")
+			}
+			fmt.Fprintf(&b, "%s (%s)\n", st.Name, st.Type)
+			if d := describe(st); d != "" {
+				fmt.Fprintf(&b, "    %s\n", d)
+			}
+			if st.Default != "" {
+				fmt.Fprintf(&b, "    default: %s\n", st.Default)
+			}
+			if st.Required {
+				b.WriteString("    required\n")
+			}
+		}
+	}
+	_, err := w.Write(b.Bytes())
+	return err
+}
+
+func describe(st Setting) string {
+	d := st.Description
+	if len(st.Choices) > 0 {
+		d = strings.TrimSpace(fmt.Sprintf("%s One of: %s.", d, strings.Join(st.Choices, ", ")))
+	}
+	if st.Subsystem != "" {
+		d = strings.TrimSpace(fmt.Sprintf("%s (%s)", d, st.Subsystem))
+	}
+	return d
+}
+
+func yesNo(b bool) string {
+	if b {
+		return "yes"
+	}
+	return "no"
+}
+
+func markdownCode(s string) string {
+	if s == "" {
+		return ""
+	}
+	return "`" + s + "`"
+}
+
+func markdownCell(s string) string {
+	return strings.Replace(s, "|", `\|`, -1)
+}
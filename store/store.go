@@ -4,7 +4,9 @@ import (
 	"bufio"
 	"bytes"
 	"errors"
-	"os"
+	"fmt"
+	"io"
+	"io/ioutil"
 	"regexp"
 	"strconv"
 	"strings"
@@ -15,9 +17,16 @@ import (
 
 type Store interface {
 	Load(string) error
-	LoadByte([]byte, string) error
+	LoadByte([]byte, string, ...Format) error
 	Add(string, string)
+	Unmarshal(string, interface{}) error
+	Dump(io.Writer) error
 	Returnr
+	Lookupr
+	Layerer
+	Reloader
+	Schemar
+	Enumerator
 }
 
 type Returnr interface {
@@ -32,22 +41,45 @@ type Returnr interface {
 type store map[string]map[string]StoreItem
 
 func New() Store {
-	return make(store)
+	return newLive()
 }
 
-func (s store) query(key string) StoreItem {
-	var sec, seckey string
+func (s store) get(section, key string) (StoreItem, bool) {
+	if k, ok := s[strings.ToUpper(section)]; ok {
+		if i, ok := k[strings.ToUpper(key)]; ok {
+			return i, true
+		}
+	}
+	return nil, false
+}
+
+// lookup finds the item for a key of the form section_key, where the first
+// underscore separates section and key. Keys in the root section containing
+// underscores, and sections containing underscores, are also tried.
+func (s store) lookup(key string) (StoreItem, bool) {
 	base := strings.Split(key, "_")
-	if len(base) == 1 {
-		sec, seckey = "", strings.ToUpper(base[0])
-	} else {
-		sec, seckey = strings.ToUpper(base[0]), strings.ToUpper(base[1])
-	}
-	if k, ok := s[strings.ToUpper(sec)]; ok {
-		if i, ok := k[seckey]; ok {
-			return i
+	if len(base) > 1 {
+		if i, ok := s.get(base[0], strings.Join(base[1:], "_")); ok {
+			return i, true
 		}
 	}
+	if i, ok := s.get("", key); ok {
+		return i, true
+	}
+	for n := 2; n < len(base); n++ {
+		if i, ok := s.get(strings.Join(base[:n], "_"), strings.Join(base[n:], "_")); ok {
+			return i, true
+		}
+	}
+	return nil, false
+}
+
+// query returns the resolved item for a key, or an empty item where the key
+// does not exist or cannot be resolved.
+func (s store) query(key string) StoreItem {
+	if i, err := s.resolveKey(key); err == nil {
+		return i
+	}
 	return &storeItem{}
 }
 
@@ -81,19 +113,52 @@ func (s store) List(key string) []string {
 	return i.List()
 }
 
+// Load reads the named file to the files layer, detecting its format from
+// the file extension.
 func (s store) Load(filename string) error {
-	file, err := os.Open(filename)
+	return s.loadFrom(filename, Files)
+}
+
+func (s store) loadFrom(filename string, l Layer) error {
+	b, err := ioutil.ReadFile(filename)
 	if err != nil {
 		return err
 	}
-	defer file.Close()
-	reader := bufio.NewReader(file)
-	return s.parse(reader, filename)
+	return s.loadByte(b, filename, Source{l, filename})
 }
 
-func (s store) LoadByte(b []byte, name string) error {
-	reader := bufio.NewReader(bytes.NewReader(b))
-	return s.parse(reader, name)
+// LoadByte loads configuration from b to the files layer, in the format given
+// or otherwise the format detected from name.
+func (s store) LoadByte(b []byte, name string, format ...Format) error {
+	return s.loadByte(b, name, Source{Files, name}, format...)
+}
+
+func (s store) loadByte(b []byte, name string, src Source, format ...Format) error {
+	f := FormatFor(name)
+	if len(format) > 0 {
+		f = format[0]
+	}
+	from := make(store)
+	var err error
+	switch f {
+	case INI:
+		err = from.parse(bufio.NewReader(bytes.NewReader(b)), name)
+	case JSON:
+		err = from.parseJSON(b, name)
+	case YAML:
+		err = from.parseYAML(b, name)
+	case TOML:
+		err = from.parseTOML(b, name)
+	case DotEnv:
+		err = from.parseEnv(b, name)
+	default:
+		err = UnknownFormat(f)
+	}
+	if err != nil {
+		return err
+	}
+	s.merge(from, src)
+	return nil
 }
 
 var StoreParseError = xrr.NewXrror("Store configuration parsing: syntax error at '%s:%d'.").Out
@@ -101,6 +166,7 @@ var StoreParseError = xrr.NewXrror("Store configuration parsing: syntax error at
 func (s store) parse(reader *bufio.Reader, filename string) (err error) {
 	lineno := 0
 	section := ""
+	var comments, header []string
 	for err == nil {
 		l, _, err := reader.ReadLine()
 		if err != nil {
@@ -111,6 +177,13 @@ func (s store) parse(reader *bufio.Reader, filename string) (err error) {
 			continue
 		}
 		line := strings.TrimFunc(string(l), unicode.IsSpace)
+		if line == "" {
+			continue
+		}
+		if line[0] == '#' || line[0] == ';' {
+			comments = append(comments, line)
+			continue
+		}
 		for line[len(line)-1] == '\\' {
 			line = line[:len(line)-1]
 			l, _, err := reader.ReadLine()
@@ -119,10 +192,22 @@ func (s store) parse(reader *bufio.Reader, filename string) (err error) {
 			}
 			line += strings.TrimFunc(string(l), unicode.IsSpace)
 		}
-		section, err = s.parseLine(section, line)
+		var key string
+		section, key, err = s.parseLine(section, line)
 		if err != nil {
 			return StoreParseError(filename, lineno)
 		}
+		if key == "" {
+			header, comments = comments, nil
+			continue
+		}
+		if i, ok := s.get(section, key); ok {
+			si := i.(*storeItem)
+			si.comments, comments = comments, nil
+			if header != nil {
+				si.header, header = header, nil
+			}
+		}
 	}
 	return err
 }
@@ -134,49 +219,43 @@ var (
 	regNoValue     = regexp.MustCompile("^([^= \t]+)[ \t]*=[ \t]*([#;].*)?")
 )
 
-func (s store) parseLine(section, line string) (string, error) {
+// parseLine parses a line, returning the current section and the key of any
+// item added.
+func (s store) parseLine(section, line string) (string, string, error) {
 	if line[0] == '#' || line[0] == ';' {
-		return section, nil
+		return section, "", nil
 	}
 
 	if line[0] == '[' && line[len(line)-1] == ']' {
 		section := strings.TrimFunc(line[1:len(line)-1], unicode.IsSpace)
 		section = strings.ToLower(section)
-		return section, nil
+		return section, "", nil
 	}
 
 	if m := regDoubleQuote.FindAllStringSubmatch(line, 1); m != nil {
 		s.add(section, m[0][1], m[0][2])
-		return section, nil
+		return section, m[0][1], nil
 	} else if m = regSingleQuote.FindAllStringSubmatch(line, 1); m != nil {
 		s.add(section, m[0][1], m[0][2])
-		return section, nil
+		return section, m[0][1], nil
 	} else if m = regNoQuote.FindAllStringSubmatch(line, 1); m != nil {
 		s.add(section, m[0][1], strings.TrimFunc(m[0][2], unicode.IsSpace))
-		return section, nil
+		return section, m[0][1], nil
 	} else if m = regNoValue.FindAllStringSubmatch(line, 1); m != nil {
 		s.add(section, m[0][1], "")
-		return section, nil
+		return section, m[0][1], nil
 	}
-	return section, errors.New("line parse error")
+	return section, "", errors.New("line parse error")
 }
 
+// Add sets an item to the set layer, taking precedence over all other layers.
 func (s store) Add(key, value string) {
-	sl := strings.Split(key, "_")
-	if len(sl) > 1 {
-		section, label := sl[0], strings.Join(sl[1:], "_")
-		s.add(section, label, value)
-	} else {
-		s.add("", sl[0], value)
-	}
+	section, k := s.locate(key)
+	s.set(section, k, value, Source{Layer: Set})
 }
 
 func (s store) add(section, key, value string) {
-	sec, seckey := strings.ToUpper(section), strings.ToUpper(key)
-	if _, ok := s[sec]; !ok {
-		s[sec] = make(map[string]StoreItem)
-	}
-	s[sec][seckey] = newItem(seckey, value)
+	s.put(section, key, newItem(key, value))
 }
 
 type StoreItem interface {
@@ -189,18 +268,32 @@ type StoreItem interface {
 }
 
 type storeItem struct {
-	Key   string
-	Value string
+	Key      string
+	Value    string
+	source   Source
+	secret   bool
+	seq      int
+	comments []string
+	header   []string
 }
 
 func newItem(key, value string) *storeItem {
-	return &storeItem{key, value}
+	return &storeItem{Key: key, Value: value}
 }
 
 func (i *storeItem) String() string {
 	return i.Value
 }
 
+// Format formats the item value, redacting the value of a secret item.
+func (i *storeItem) Format(f fmt.State, c rune) {
+	if i.secret {
+		io.WriteString(f, Redacted)
+		return
+	}
+	io.WriteString(f, i.Value)
+}
+
 var boolString = map[string]bool{
 	"t":     true,
 	"true":  true,
@@ -245,8 +338,5 @@ func (i *storeItem) Int64() int64 {
 }
 
 func (i *storeItem) List() []string {
-	if i.Value == "" {
-		return nil
-	}
-	return strings.Split(i.Value, ",")
+	return splitList(i.Value)
 }
@@ -0,0 +1,518 @@
+package store
+
+import (
+	"bytes"
+	"errors"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// tomlParser parses the commonly used subset of TOML: tables, arrays of
+// tables, dotted and quoted keys, basic, literal and multi-line strings,
+// numbers, booleans, date-times (kept as text), arrays and inline tables.
+// Redefined keys and tables, keys extending inline tables, and malformed
+// numbers are rejected; date-times are not validated.
+type tomlParser struct {
+	src     string
+	pos     int
+	line    int
+	root    *object
+	current *object
+	defined map[*object]bool
+	inline  map[*object]bool
+}
+
+var errTOML = errors.New("toml syntax error")
+
+func (s store) parseTOML(b []byte, name string) error {
+	p := &tomlParser{
+		src:     string(b),
+		line:    1,
+		root:    newObject(),
+		defined: make(map[*object]bool),
+		inline:  make(map[*object]bool),
+	}
+	p.current = p.root
+	if err := p.parse(); err != nil {
+		return StoreParseError(name, p.line)
+	}
+	s.loadObject(p.root)
+	return nil
+}
+
+func (p *tomlParser) eof() bool {
+	return p.pos >= len(p.src)
+}
+
+func (p *tomlParser) peek() byte {
+	if p.eof() {
+		return 0
+	}
+	return p.src[p.pos]
+}
+
+func (p *tomlParser) hasPrefix(s string) bool {
+	return strings.HasPrefix(p.src[p.pos:], s)
+}
+
+func (p *tomlParser) next() byte {
+	c := p.src[p.pos]
+	p.pos++
+	if c == '\n' {
+		p.line++
+	}
+	return c
+}
+
+func (p *tomlParser) skipSpace() {
+	for !p.eof() && (p.peek() == ' ' || p.peek() == '\t') {
+		p.next()
+	}
+}
+
+func (p *tomlParser) skipComment() {
+	if p.peek() == '#' {
+		for !p.eof() && p.peek() != '\n' {
+			p.next()
+		}
+	}
+}
+
+// skipAll skips whitespace, newlines and comments.
+func (p *tomlParser) skipAll() {
+	for !p.eof() {
+		switch p.peek() {
+		case ' ', '\t', '\r', '\n':
+			p.next()
+		case '#':
+			p.skipComment()
+		default:
+			return
+		}
+	}
+}
+
+func (p *tomlParser) endOfLine() error {
+	p.skipSpace()
+	p.skipComment()
+	if p.peek() == '\r' {
+		p.next()
+	}
+	if p.eof() {
+		return nil
+	}
+	if p.peek() != '\n' {
+		return errTOML
+	}
+	p.next()
+	return nil
+}
+
+func (p *tomlParser) parse() error {
+	for {
+		p.skipAll()
+		if p.eof() {
+			return nil
+		}
+		var err error
+		if p.peek() == '[' {
+			err = p.parseTable()
+		} else {
+			err = p.parseKeyValue(p.current)
+			if err == nil {
+				err = p.endOfLine()
+			}
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func (p *tomlParser) parseTable() error {
+	array := p.hasPrefix("[[")
+	p.next()
+	if array {
+		p.next()
+	}
+	keys, err := p.parseKey()
+	if err != nil {
+		return err
+	}
+	closing := "]"
+	if array {
+		closing = "]]"
+	}
+	if !p.hasPrefix(closing) {
+		return errTOML
+	}
+	p.pos += len(closing)
+	parent, err := p.walk(p.root, keys[:len(keys)-1])
+	if err != nil {
+		return err
+	}
+	last := keys[len(keys)-1]
+	if array {
+		o := newObject()
+		existing, _ := parent.get(last)
+		l, ok := existing.([]interface{})
+		if existing != nil && !ok {
+			return errTOML
+		}
+		parent.set(last, append(l, o))
+		p.current = o
+	} else {
+		o, err := p.walk(parent, []string{last})
+		if err != nil {
+			return err
+		}
+		if p.defined[o] {
+			return errTOML
+		}
+		p.defined[o] = true
+		p.current = o
+	}
+	return p.endOfLine()
+}
+
+// walk returns the object at the path of keys below o, creating objects as
+// needed; where a key holds an array of tables, its last table is used.
+func (p *tomlParser) walk(o *object, keys []string) (*object, error) {
+	for _, k := range keys {
+		v, ok := o.get(k)
+		if !ok {
+			n := newObject()
+			o.set(k, n)
+			o = n
+			continue
+		}
+		switch vv := v.(type) {
+		case *object:
+			if p.inline[vv] {
+				return nil, errTOML
+			}
+			o = vv
+		case []interface{}:
+			if len(vv) == 0 {
+				return nil, errTOML
+			}
+			last, ok := vv[len(vv)-1].(*object)
+			if !ok {
+				return nil, errTOML
+			}
+			o = last
+		default:
+			return nil, errTOML
+		}
+	}
+	return o, nil
+}
+
+func isBareKey(c byte) bool {
+	return c == '_' || c == '-' ||
+		(c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')
+}
+
+func (p *tomlParser) parseKey() ([]string, error) {
+	var keys []string
+	for {
+		p.skipSpace()
+		var key string
+		switch c := p.peek(); {
+		case c == '"':
+			p.next()
+			k, err := p.basicString()
+			if err != nil {
+				return nil, err
+			}
+			key = k
+		case c == '\'':
+			p.next()
+			k, err := p.literalString()
+			if err != nil {
+				return nil, err
+			}
+			key = k
+		case isBareKey(c):
+			start := p.pos
+			for !p.eof() && isBareKey(p.peek()) {
+				p.next()
+			}
+			key = p.src[start:p.pos]
+		default:
+			return nil, errTOML
+		}
+		keys = append(keys, key)
+		p.skipSpace()
+		if p.peek() != '.' {
+			return keys, nil
+		}
+		p.next()
+	}
+}
+
+func (p *tomlParser) parseKeyValue(o *object) error {
+	keys, err := p.parseKey()
+	if err != nil {
+		return err
+	}
+	if p.peek() != '=' {
+		return errTOML
+	}
+	p.next()
+	p.skipSpace()
+	v, err := p.parseValue()
+	if err != nil {
+		return err
+	}
+	parent, err := p.walk(o, keys[:len(keys)-1])
+	if err != nil {
+		return err
+	}
+	if _, exists := parent.get(keys[len(keys)-1]); exists {
+		return errTOML
+	}
+	parent.set(keys[len(keys)-1], v)
+	return nil
+}
+
+func (p *tomlParser) parseValue() (interface{}, error) {
+	switch {
+	case p.hasPrefix(`"""`):
+		p.pos += 3
+		return p.multilineBasicString()
+	case p.hasPrefix(`'''`):
+		p.pos += 3
+		return p.multilineLiteralString()
+	case p.peek() == '"':
+		p.next()
+		return p.basicString()
+	case p.peek() == '\'':
+		p.next()
+		return p.literalString()
+	case p.peek() == '[':
+		p.next()
+		return p.parseArray()
+	case p.peek() == '{':
+		p.next()
+		return p.parseInlineTable()
+	}
+	return p.parseBare()
+}
+
+func (p *tomlParser) parseArray() (interface{}, error) {
+	l := make([]interface{}, 0)
+	for {
+		p.skipAll()
+		if p.peek() == ']' {
+			p.next()
+			return l, nil
+		}
+		v, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		l = append(l, v)
+		p.skipAll()
+		switch p.peek() {
+		case ',':
+			p.next()
+		case ']':
+		default:
+			return nil, errTOML
+		}
+	}
+}
+
+func (p *tomlParser) parseInlineTable() (interface{}, error) {
+	o := newObject()
+	defer func() { p.inline[o] = true }()
+	p.skipSpace()
+	if p.peek() == '}' {
+		p.next()
+		return o, nil
+	}
+	for {
+		if err := p.parseKeyValue(o); err != nil {
+			return nil, err
+		}
+		p.skipSpace()
+		switch p.peek() {
+		case ',':
+			p.next()
+		case '}':
+			p.next()
+			return o, nil
+		default:
+			return nil, errTOML
+		}
+	}
+}
+
+var (
+	tomlNumber = regexp.MustCompile(`^([+-]?(0|[1-9](_?[0-9])*)(\.[0-9](_?[0-9])*)?([eE][+-]?[0-9](_?[0-9])*)?|0x[0-9a-fA-F](_?[0-9a-fA-F])*|0o[0-7](_?[0-7])*|0b[01](_?[01])*|[+-]?(inf|nan))$`)
+	tomlDate   = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}`)
+	tomlTime   = regexp.MustCompile(`^\d{2}:\d{2}`)
+)
+
+func (p *tomlParser) bareToken() string {
+	start := p.pos
+	for !p.eof() {
+		switch p.peek() {
+		case ' ', '\t', '\r', '\n', ',', ']', '}', '#':
+			return p.src[start:p.pos]
+		}
+		p.next()
+	}
+	return p.src[start:p.pos]
+}
+
+func (p *tomlParser) parseBare() (interface{}, error) {
+	token := p.bareToken()
+	switch {
+	case token == "true" || token == "false":
+		return token, nil
+	case tomlDate.MatchString(token):
+		if p.peek() == ' ' && p.pos+1 < len(p.src) && tomlTime.MatchString(p.src[p.pos+1:]) {
+			p.next()
+			token = token + " " + p.bareToken()
+		}
+		return token, nil
+	case tomlTime.MatchString(token):
+		return token, nil
+	case tomlNumber.MatchString(token):
+		token = strings.Replace(token, "_", "", -1)
+		if n, err := strconv.ParseInt(token, 0, 64); err == nil {
+			return strconv.FormatInt(n, 10), nil
+		}
+		return token, nil
+	}
+	return nil, errTOML
+}
+
+func (p *tomlParser) escape() (string, error) {
+	if p.eof() {
+		return "", errTOML
+	}
+	switch c := p.next(); c {
+	case 'b':
+		return "\b", nil
+	case 't':
+		return "\t", nil
+	case 'n':
+		return "\n", nil
+	case 'f':
+		return "\f", nil
+	case 'r':
+		return "\r", nil
+	case '"':
+		return `"`, nil
+	case '\\':
+		return `\`, nil
+	case 'u', 'U':
+		n := 4
+		if c == 'U' {
+			n = 8
+		}
+		if p.pos+n > len(p.src) {
+			return "", errTOML
+		}
+		r, err := strconv.ParseUint(p.src[p.pos:p.pos+n], 16, 32)
+		if err != nil {
+			return "", errTOML
+		}
+		p.pos += n
+		buf := make([]byte, utf8.UTFMax)
+		return string(buf[:utf8.EncodeRune(buf, rune(r))]), nil
+	}
+	return "", errTOML
+}
+
+func (p *tomlParser) basicString() (string, error) {
+	var b bytes.Buffer
+	for !p.eof() {
+		c := p.next()
+		switch c {
+		case '"':
+			return b.String(), nil
+		case '\n':
+			return "", errTOML
+		case '\\':
+			e, err := p.escape()
+			if err != nil {
+				return "", err
+			}
+			b.WriteString(e)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return "", errTOML
+}
+
+func (p *tomlParser) literalString() (string, error) {
+	start := p.pos
+	for !p.eof() {
+		switch p.next() {
+		case '\'':
+			return p.src[start : p.pos-1], nil
+		case '\n':
+			return "", errTOML
+		}
+	}
+	return "", errTOML
+}
+
+func (p *tomlParser) trimFirstNewline() {
+	if p.hasPrefix("\r\n") {
+		p.next()
+	}
+	if p.peek() == '\n' {
+		p.next()
+	}
+}
+
+func (p *tomlParser) multilineBasicString() (string, error) {
+	p.trimFirstNewline()
+	var b bytes.Buffer
+	for !p.eof() {
+		if p.hasPrefix(`"""`) {
+			p.pos += 3
+			return b.String(), nil
+		}
+		c := p.next()
+		if c != '\\' {
+			b.WriteByte(c)
+			continue
+		}
+		if p.peek() == '\n' || p.peek() == '\r' || p.peek() == ' ' || p.peek() == '\t' {
+			// a line ending backslash trims all following whitespace
+			for !p.eof() && strings.IndexByte(" \t\r\n", p.peek()) >= 0 {
+				p.next()
+			}
+			continue
+		}
+		e, err := p.escape()
+		if err != nil {
+			return "", err
+		}
+		b.WriteString(e)
+	}
+	return "", errTOML
+}
+
+func (p *tomlParser) multilineLiteralString() (string, error) {
+	p.trimFirstNewline()
+	start := p.pos
+	for !p.eof() {
+		if p.hasPrefix(`'''`) {
+			s := p.src[start:p.pos]
+			p.pos += 3
+			return s, nil
+		}
+		p.next()
+	}
+	return "", errTOML
+}
@@ -0,0 +1,298 @@
+package store
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/flxtilla/cxre/xrr"
+)
+
+// Enumerator lists the items of a store and writes them out.
+type Enumerator interface {
+	Sections() []string
+	Keys(string) []string
+	Each(func(string, StoreItem))
+	Write(io.Writer, Format) error
+	WriteEnv(io.Writer, string) error
+}
+
+type entry struct {
+	section, key string
+	item         *storeItem
+}
+
+func (e entry) name() string {
+	return itemName(e.section, e.key)
+}
+
+// ordered returns every item in order: items of the root section first,
+// then each section in the order its first item was added, with the items
+// of a section in the order they were added.
+func (s store) ordered() []entry {
+	var entries []entry
+	first := make(map[string]int)
+	for section, items := range s {
+		for key, i := range items {
+			si, ok := i.(*storeItem)
+			if !ok {
+				si = newItem(key, i.String())
+			}
+			entries = append(entries, entry{section, key, si})
+			if f, ok := first[section]; !ok || si.seq < f {
+				first[section] = si.seq
+			}
+		}
+	}
+	first[""] = -1
+	sort.Slice(entries, func(a, b int) bool {
+		ea, eb := entries[a], entries[b]
+		if ea.section != eb.section {
+			return first[ea.section] < first[eb.section]
+		}
+		return ea.item.seq < eb.item.seq
+	})
+	return entries
+}
+
+// Sections returns the names of the sections in order, beginning with the
+// root section as an empty string when it has items.
+func (s store) Sections() []string {
+	var sections []string
+	for _, e := range s.ordered() {
+		section := strings.ToLower(e.section)
+		if len(sections) == 0 || sections[len(sections)-1] != section {
+			sections = append(sections, section)
+		}
+	}
+	return sections
+}
+
+// Keys returns the keys of the named section in order.
+func (s store) Keys(section string) []string {
+	var keys []string
+	for _, e := range s.ordered() {
+		if strings.EqualFold(e.section, section) {
+			keys = append(keys, strings.ToLower(e.key))
+		}
+	}
+	return keys
+}
+
+// Each calls fn with the name and unresolved item of every item in order.
+func (s store) Each(fn func(string, StoreItem)) {
+	for _, e := range s.ordered() {
+		fn(e.name(), e.item)
+	}
+}
+
+// Write writes every item, unresolved, in the INI, JSON or DotEnv format.
+// Items keep their order, and items parsed from an INI file or env file keep
+// their comments where the format allows.
+func (s store) Write(w io.Writer, f Format) error {
+	switch f {
+	case INI:
+		return s.writeINI(w)
+	case JSON:
+		return s.writeJSON(w)
+	case DotEnv:
+		return s.WriteEnv(w, "")
+	}
+	return UnknownFormat(f)
+}
+
+func writeComments(b *bytes.Buffer, comments []string) {
+	for _, c := range comments {
+		b.WriteString(c)
+		b.WriteString("\n")
+	}
+}
+
+var UnwritableValue = xrr.NewXrror("Store item %s cannot be written as %s: its value holds a line break or both kinds of quote.").Out
+
+// iniValue returns a value as written in an INI file, quoted where it would
+// otherwise be read differently, and a boolean indicating if the INI reader
+// can read it at all, being false for a value holding a line break or both
+// kinds of quote that must be quoted.
+func iniValue(v string) (string, bool) {
+	switch {
+	case v == "":
+		return "", true
+	case strings.ContainsAny(v, "\r\n"):
+		return "", false
+	case strings.ContainsAny(v, "#;") || strings.TrimSpace(v) != v || v[0] == '"' || v[0] == '\'' || strings.HasSuffix(v, "\\"):
+		if !strings.Contains(v, `"`) {
+			return `"` + v + `"`, true
+		}
+		if !strings.Contains(v, "'") {
+			return "'" + v + "'", true
+		}
+		return "", false
+	}
+	return v, true
+}
+
+// writeINI writes every item in the INI format, returning an error without
+// writing anything where an item value cannot be read back.
+func (s store) writeINI(w io.Writer) error {
+	var b bytes.Buffer
+	section := ""
+	for _, e := range s.ordered() {
+		if e.section != section {
+			section = e.section
+			if b.Len() > 0 {
+				b.WriteString("\n")
+			}
+			writeComments(&b, s.header(section))
+			fmt.Fprintf(&b, "[%s]\n", strings.ToLower(section))
+		}
+		v, ok := iniValue(e.item.Value)
+		if !ok {
+			return UnwritableValue(e.name(), INI)
+		}
+		writeComments(&b, e.item.comments)
+		fmt.Fprintf(&b, "%s = %s\n", strings.ToLower(e.key), v)
+	}
+	_, err := w.Write(b.Bytes())
+	return err
+}
+
+// header returns the comments preceding the header of a parsed section.
+func (s store) header(section string) []string {
+	var first *storeItem
+	for _, i := range s[section] {
+		if si, ok := i.(*storeItem); ok && len(si.header) > 0 && (first == nil || si.seq < first.seq) {
+			first = si
+		}
+	}
+	if first == nil {
+		return nil
+	}
+	return first.header
+}
+
+func jsonString(s string) string {
+	b, _ := json.Marshal(s)
+	return string(b)
+}
+
+func (s store) writeJSON(w io.Writer) error {
+	var members, items []string
+	section := ""
+	flush := func() {
+		if section != "" {
+			members = append(members, fmt.Sprintf("\n  %s: {%s\n  }", jsonString(strings.ToLower(section)), strings.Join(items, ",")))
+		}
+	}
+	for _, e := range s.ordered() {
+		if e.section != section {
+			flush()
+			section, items = e.section, nil
+		}
+		m := fmt.Sprintf("%s: %s", jsonString(strings.ToLower(e.key)), jsonString(e.item.Value))
+		if section == "" {
+			members = append(members, "\n  "+m)
+		} else {
+			items = append(items, "\n    "+m)
+		}
+	}
+	flush()
+	_, err := fmt.Fprintf(w, "{%s\n}\n", strings.Join(members, ","))
+	return err
+}
+
+var plainEnv = regexp.MustCompile(`^[A-Za-z0-9_./:,@%+=-]*$`)
+
+func envValue(v string) string {
+	if plainEnv.MatchString(v) {
+		return v
+	}
+	return strconv.Quote(v)
+}
+
+// WriteEnv writes every item, unresolved, as an env file of variables named
+// by the prefix, an underscore and the upper cased item name, e.g.
+// APP_SESSION_LIFETIME, the names LoadEnv reads with the same prefix.
+func (s store) WriteEnv(w io.Writer, prefix string) error {
+	var b bytes.Buffer
+	if prefix != "" {
+		prefix = strings.ToUpper(prefix) + "_"
+	}
+	section := ""
+	for _, e := range s.ordered() {
+		if e.section != section {
+			section = e.section
+			writeComments(&b, envComments(s.header(section)))
+		}
+		writeComments(&b, envComments(e.item.comments))
+		fmt.Fprintf(&b, "%s%s=%s\n", prefix, strings.ToUpper(e.name()), envValue(e.item.Value))
+	}
+	_, err := w.Write(b.Bytes())
+	return err
+}
+
+func envComments(comments []string) []string {
+	var ret []string
+	for _, c := range comments {
+		if strings.HasPrefix(c, ";") {
+			c = "#" + c[1:]
+		}
+		ret = append(ret, c)
+	}
+	return ret
+}
+
+var regEnv = regexp.MustCompile(`^(?:export[ \t]+)?([A-Za-z_][A-Za-z0-9_]*)[ \t]*=[ \t]*(.*)$`)
+
+// parseEnv parses an env file of NAME=value lines, where values may be
+// double quoted with escapes, single quoted, or bare with trailing comments.
+func (s store) parseEnv(b []byte, name string) error {
+	scanner := bufio.NewScanner(bytes.NewReader(b))
+	var comments []string
+	lineno := 0
+	for scanner.Scan() {
+		lineno++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		if line[0] == '#' {
+			comments = append(comments, line)
+			continue
+		}
+		m := regEnv.FindStringSubmatch(line)
+		if m == nil {
+			return StoreParseError(name, lineno)
+		}
+		value := m[2]
+		switch {
+		case strings.HasPrefix(value, `"`):
+			v, err := strconv.Unquote(value)
+			if err != nil {
+				return StoreParseError(name, lineno)
+			}
+			value = v
+		case strings.HasPrefix(value, "'"):
+			if len(value) < 2 || !strings.HasSuffix(value, "'") {
+				return StoreParseError(name, lineno)
+			}
+			value = value[1 : len(value)-1]
+		default:
+			if i := strings.Index(value, " #"); i >= 0 {
+				value = value[:i]
+			}
+			value = strings.TrimSpace(value)
+		}
+		section, key := s.locate(m[1])
+		i := newItem(key, value)
+		i.comments, comments = comments, nil
+		s.put(section, key, i)
+	}
+	return scanner.Err()
+}
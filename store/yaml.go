@@ -0,0 +1,522 @@
+package store
+
+import (
+	"bytes"
+	"errors"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// yamlParser parses the subset of YAML commonly used for configuration:
+// block mappings and sequences, flow sequences and mappings, plain, single
+// and double quoted scalars, literal (|) and folded (>) block scalars, and
+// comments. Anchors, aliases, tags and multiple documents are not supported,
+// and are rejected along with tab indentation and duplicate keys.
+type yamlParser struct {
+	lines []string
+	i     int
+}
+
+var errYAML = errors.New("yaml syntax error")
+
+func (s store) parseYAML(b []byte, name string) error {
+	p := &yamlParser{lines: strings.Split(strings.Replace(string(b), "\r\n", "\n", -1), "\n")}
+	v, err := p.parseDocument()
+	if err != nil {
+		return StoreParseError(name, p.i+1)
+	}
+	switch o := v.(type) {
+	case *object:
+		s.loadObject(o)
+	case nil:
+	default:
+		return StoreParseError(name, 1)
+	}
+	return nil
+}
+
+func indentOf(line string) int {
+	return len(line) - len(strings.TrimLeft(line, " "))
+}
+
+// tabIndented returns a boolean indicating if a line is indented with a tab,
+// which YAML forbids.
+func tabIndented(line string) bool {
+	return strings.HasPrefix(strings.TrimLeft(line, " "), "\t")
+}
+
+func isYAMLBlank(line string) bool {
+	t := strings.TrimSpace(line)
+	return t == "" || t[0] == '#'
+}
+
+// significant advances past blank and comment lines, returning false at the
+// end of input or at a document end marker.
+func (p *yamlParser) significant() bool {
+	for p.i < len(p.lines) {
+		line := p.lines[p.i]
+		if line == "..." || strings.HasPrefix(line, "--- ") || line == "---" {
+			return false
+		}
+		if !isYAMLBlank(line) {
+			return true
+		}
+		p.i++
+	}
+	return false
+}
+
+func (p *yamlParser) parseDocument() (interface{}, error) {
+	for p.i < len(p.lines) {
+		line := p.lines[p.i]
+		if isYAMLBlank(line) || strings.HasPrefix(line, "%") {
+			p.i++
+			continue
+		}
+		if line == "---" {
+			p.i++
+		}
+		break
+	}
+	if !p.significant() {
+		return nil, nil
+	}
+	v, err := p.parseNode(indentOf(p.lines[p.i]))
+	if err != nil {
+		return nil, err
+	}
+	if p.significant() {
+		return nil, errYAML
+	}
+	if p.i < len(p.lines) && strings.HasPrefix(p.lines[p.i], "---") {
+		// a further document
+		for n := p.i + 1; n < len(p.lines); n++ {
+			if !isYAMLBlank(p.lines[n]) {
+				return nil, errYAML
+			}
+		}
+		if strings.TrimSpace(strings.TrimPrefix(p.lines[p.i], "---")) != "" {
+			return nil, errYAML
+		}
+	}
+	return v, nil
+}
+
+func isSequenceItem(text string) bool {
+	return text == "-" || strings.HasPrefix(text, "- ")
+}
+
+// parseNode parses the block node starting at the current line, which must
+// be indented by exactly indent spaces.
+func (p *yamlParser) parseNode(indent int) (interface{}, error) {
+	text := strings.TrimSpace(p.lines[p.i])
+	if indentOf(p.lines[p.i]) != indent || tabIndented(p.lines[p.i]) {
+		return nil, errYAML
+	}
+	if isSequenceItem(text) {
+		return p.parseSequence(indent)
+	}
+	if _, _, ok := splitYAMLMapping(text); ok {
+		return p.parseMapping(indent)
+	}
+	p.i++
+	return parseYAMLInline(text)
+}
+
+func (p *yamlParser) parseMapping(indent int) (interface{}, error) {
+	o := newObject()
+	for p.significant() {
+		line := p.lines[p.i]
+		n := indentOf(line)
+		if n < indent {
+			break
+		}
+		if n > indent || tabIndented(line) {
+			return nil, errYAML
+		}
+		key, rest, ok := splitYAMLMapping(strings.TrimSpace(line))
+		if !ok {
+			return nil, errYAML
+		}
+		if _, exists := o.get(key); exists {
+			return nil, errYAML
+		}
+		p.i++
+		v, err := p.parseValue(indent, rest, true)
+		if err != nil {
+			return nil, err
+		}
+		o.set(key, v)
+	}
+	return o, nil
+}
+
+func (p *yamlParser) parseSequence(indent int) (interface{}, error) {
+	l := make([]interface{}, 0)
+	for p.significant() {
+		line := p.lines[p.i]
+		n := indentOf(line)
+		if n < indent {
+			break
+		}
+		text := strings.TrimSpace(line)
+		if n > indent || tabIndented(line) || !isSequenceItem(text) {
+			return nil, errYAML
+		}
+		item := strings.TrimLeft(text[1:], " ")
+		_, _, mapping := splitYAMLMapping(item)
+		if mapping || isSequenceItem(item) {
+			// a compact nested node; reparse the line at the item column
+			col := n + len(text) - len(item)
+			p.lines[p.i] = strings.Repeat(" ", col) + item
+			v, err := p.parseNode(col)
+			if err != nil {
+				return nil, err
+			}
+			l = append(l, v)
+			continue
+		}
+		p.i++
+		v, err := p.parseValue(indent, item, false)
+		if err != nil {
+			return nil, err
+		}
+		l = append(l, v)
+	}
+	return l, nil
+}
+
+// parseValue parses the value following a mapping key or sequence indicator,
+// either inline, as a block scalar, or as a nested block node.
+func (p *yamlParser) parseValue(indent int, rest string, inMapping bool) (interface{}, error) {
+	rest = stripYAMLComment(rest)
+	if rest == "" {
+		if !p.significant() {
+			return "", nil
+		}
+		line := p.lines[p.i]
+		n := indentOf(line)
+		if tabIndented(line) {
+			return nil, errYAML
+		}
+		if n > indent || (inMapping && n == indent && isSequenceItem(strings.TrimSpace(line))) {
+			return p.parseNode(n)
+		}
+		return "", nil
+	}
+	if rest[0] == '|' || rest[0] == '>' {
+		return p.parseBlockScalar(indent, rest)
+	}
+	return parseYAMLInline(rest)
+}
+
+func (p *yamlParser) parseBlockScalar(indent int, header string) (interface{}, error) {
+	folded := header[0] == '>'
+	chomp := strings.TrimSpace(header[1:])
+	var lines []string
+	block := -1
+	for p.i < len(p.lines) {
+		line := p.lines[p.i]
+		if strings.TrimSpace(line) == "" {
+			lines = append(lines, "")
+			p.i++
+			continue
+		}
+		n := indentOf(line)
+		if n <= indent {
+			break
+		}
+		if block == -1 {
+			block = n
+		}
+		if n < block {
+			return nil, errYAML
+		}
+		lines = append(lines, line[block:])
+		p.i++
+	}
+	var trailing int
+	for len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+		trailing++
+	}
+	var b bytes.Buffer
+	for i, line := range lines {
+		if i > 0 {
+			if folded && line != "" && lines[i-1] != "" {
+				b.WriteString(" ")
+			} else {
+				b.WriteString("\n")
+			}
+		}
+		b.WriteString(line)
+	}
+	switch chomp {
+	case "-":
+	case "+":
+		b.WriteString(strings.Repeat("\n", trailing+1))
+	default:
+		if len(lines) > 0 {
+			b.WriteString("\n")
+		}
+	}
+	return b.String(), nil
+}
+
+// splitYAMLMapping splits a "key: value" line, returning false if the line
+// is not a mapping entry.
+func splitYAMLMapping(text string) (string, string, bool) {
+	if text == "" || text[0] == '[' || text[0] == '{' || text[0] == '#' {
+		return "", "", false
+	}
+	if text[0] == '"' || text[0] == '\'' {
+		end := closingQuote(text)
+		if end == -1 || end+1 >= len(text) || text[end+1] != ':' {
+			return "", "", false
+		}
+		key, err := parseYAMLScalar(text[:end+1])
+		if err != nil {
+			return "", "", false
+		}
+		rest := text[end+2:]
+		if rest != "" && rest[0] != ' ' {
+			return "", "", false
+		}
+		return key, strings.TrimSpace(rest), true
+	}
+	for i := 0; i < len(text); i++ {
+		if text[i] == '#' && i > 0 && text[i-1] == ' ' {
+			return "", "", false
+		}
+		if text[i] == ':' && (i+1 == len(text) || text[i+1] == ' ') {
+			return strings.TrimSpace(text[:i]), strings.TrimSpace(text[i+1:]), true
+		}
+	}
+	return "", "", false
+}
+
+func closingQuote(text string) int {
+	q := text[0]
+	for i := 1; i < len(text); i++ {
+		switch {
+		case q == '"' && text[i] == '\\':
+			i++
+		case q == '\'' && text[i] == '\'' && i+1 < len(text) && text[i+1] == '\'':
+			i++
+		case text[i] == q:
+			return i
+		}
+	}
+	return -1
+}
+
+// stripYAMLComment removes a trailing comment outside of quotes.
+func stripYAMLComment(text string) string {
+	var quote byte
+	for i := 0; i < len(text); i++ {
+		c := text[i]
+		switch {
+		case quote != 0:
+			if c == '\\' && quote == '"' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '#' && (i == 0 || text[i-1] == ' ' || text[i-1] == '\t'):
+			return strings.TrimSpace(text[:i])
+		}
+	}
+	return strings.TrimSpace(text)
+}
+
+func parseYAMLInline(text string) (interface{}, error) {
+	text = stripYAMLComment(text)
+	if text != "" && (text[0] == '[' || text[0] == '{') {
+		f := &yamlFlow{src: text}
+		v, err := f.parse()
+		if err != nil {
+			return nil, err
+		}
+		f.skipSpace()
+		if f.pos != len(f.src) {
+			return nil, errYAML
+		}
+		return v, nil
+	}
+	return parseYAMLScalar(text)
+}
+
+func parseYAMLScalar(text string) (string, error) {
+	if text == "" || text == "~" || text == "null" || text == "Null" || text == "NULL" {
+		return "", nil
+	}
+	switch text[0] {
+	case '\'':
+		if len(text) < 2 || text[len(text)-1] != '\'' {
+			return "", errYAML
+		}
+		return strings.Replace(text[1:len(text)-1], "''", "'", -1), nil
+	case '"':
+		if len(text) < 2 || text[len(text)-1] != '"' {
+			return "", errYAML
+		}
+		return unescapeYAML(text[1 : len(text)-1])
+	case '&', '*', '!', '@', '`', '%', '|', '>':
+		// anchors, aliases, tags and reserved indicators
+		return "", errYAML
+	}
+	if strings.Contains(text, ": ") || strings.HasSuffix(text, ":") {
+		// a mapping indicator, e.g. key: value: bad, is not a plain scalar
+		return "", errYAML
+	}
+	return text, nil
+}
+
+func unescapeYAML(s string) (string, error) {
+	var b bytes.Buffer
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' {
+			b.WriteByte(s[i])
+			continue
+		}
+		i++
+		if i >= len(s) {
+			return "", errYAML
+		}
+		switch s[i] {
+		case 'n':
+			b.WriteByte('\n')
+		case 't':
+			b.WriteByte('\t')
+		case 'r':
+			b.WriteByte('\r')
+		case '0':
+			b.WriteByte(0)
+		case '"', '\\', '/', ' ':
+			b.WriteByte(s[i])
+		case 'x', 'u', 'U':
+			n := map[byte]int{'x': 2, 'u': 4, 'U': 8}[s[i]]
+			if i+1+n > len(s) {
+				return "", errYAML
+			}
+			r, err := strconv.ParseUint(s[i+1:i+1+n], 16, 32)
+			if err != nil {
+				return "", errYAML
+			}
+			buf := make([]byte, utf8.UTFMax)
+			b.Write(buf[:utf8.EncodeRune(buf, rune(r))])
+			i += n
+		default:
+			return "", errYAML
+		}
+	}
+	return b.String(), nil
+}
+
+// yamlFlow parses flow sequences and mappings, e.g. [a, b] and {a: 1}.
+type yamlFlow struct {
+	src string
+	pos int
+}
+
+func (f *yamlFlow) skipSpace() {
+	for f.pos < len(f.src) && (f.src[f.pos] == ' ' || f.src[f.pos] == '\t') {
+		f.pos++
+	}
+}
+
+func (f *yamlFlow) parse() (interface{}, error) {
+	f.skipSpace()
+	if f.pos >= len(f.src) {
+		return nil, errYAML
+	}
+	switch f.src[f.pos] {
+	case '[':
+		f.pos++
+		l := make([]interface{}, 0)
+		for {
+			f.skipSpace()
+			if f.pos < len(f.src) && f.src[f.pos] == ']' {
+				f.pos++
+				return l, nil
+			}
+			v, err := f.parse()
+			if err != nil {
+				return nil, err
+			}
+			l = append(l, v)
+			if err := f.separator(']'); err != nil {
+				return nil, err
+			}
+		}
+	case '{':
+		f.pos++
+		o := newObject()
+		for {
+			f.skipSpace()
+			if f.pos < len(f.src) && f.src[f.pos] == '}' {
+				f.pos++
+				return o, nil
+			}
+			k, err := f.scalar(true)
+			if err != nil {
+				return nil, err
+			}
+			f.skipSpace()
+			if f.pos >= len(f.src) || f.src[f.pos] != ':' {
+				return nil, errYAML
+			}
+			f.pos++
+			v, err := f.parse()
+			if err != nil {
+				return nil, err
+			}
+			if _, exists := o.get(k); exists {
+				return nil, errYAML
+			}
+			o.set(k, v)
+			if err := f.separator('}'); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return f.scalar(false)
+}
+
+func (f *yamlFlow) separator(closing byte) error {
+	f.skipSpace()
+	if f.pos >= len(f.src) {
+		return errYAML
+	}
+	switch f.src[f.pos] {
+	case ',':
+		f.pos++
+		return nil
+	case closing:
+		return nil
+	}
+	return errYAML
+}
+
+func (f *yamlFlow) scalar(key bool) (string, error) {
+	start := f.pos
+	if f.pos < len(f.src) && (f.src[f.pos] == '"' || f.src[f.pos] == '\'') {
+		end := closingQuote(f.src[f.pos:])
+		if end == -1 {
+			return "", errYAML
+		}
+		f.pos += end + 1
+		return parseYAMLScalar(f.src[start:f.pos])
+	}
+	for f.pos < len(f.src) {
+		c := f.src[f.pos]
+		if c == ',' || c == ']' || c == '}' || (key && c == ':') {
+			break
+		}
+		f.pos++
+	}
+	return parseYAMLScalar(strings.TrimSpace(f.src[start:f.pos]))
+}
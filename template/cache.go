@@ -0,0 +1,65 @@
+package template
+
+import (
+	"os"
+	"time"
+
+	"github.com/flxtilla/cxre/state"
+	"github.com/flxtilla/cxre/xrr"
+)
+
+// CacheMode determines how a Templater caches templates.
+type CacheMode int
+
+const (
+	// Development reloads templates modified on disk, re-parsing them on the
+	// next render.
+	Development CacheMode = iota
+
+	// Production loads templates once, and may parse and cache every
+	// template at startup with Precompile.
+	Production
+)
+
+func (m CacheMode) String() string {
+	if m == Production {
+		return "production"
+	}
+	return "development"
+}
+
+// ModeFor returns the CacheMode for the app mode of a state, being Production
+// where mode_is("production") is true and Development otherwise.
+func ModeFor(s state.State) CacheMode {
+	if production(s) {
+		return Production
+	}
+	return Development
+}
+
+// Cacher is implemented by a Templater that caches templates, in Development
+// mode until its CacheMode is set.
+type Cacher interface {
+	CacheMode() CacheMode
+	SetCacheMode(CacheMode)
+	Precompile() error
+	Flush()
+}
+
+var TemplateSyntaxError = xrr.NewXrror("Template %s has a syntax error: %s").Out
+
+// source is the text of a template, with the file and modification time it
+// was read from; templates read from assets have no file.
+type source struct {
+	path    string
+	modTime time.Time
+	text    string
+}
+
+func (s *source) modified() bool {
+	if s.path == "" {
+		return false
+	}
+	fi, err := os.Stat(s.path)
+	return err != nil || !fi.ModTime().Equal(s.modTime)
+}
@@ -0,0 +1,200 @@
+package template
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"net/http"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/flxtilla/cxre/state"
+	"github.com/flxtilla/cxre/xrr"
+)
+
+// TemplateError is a template that failed to parse or execute, with the
+// position of the failure where the error gives one, the source lines
+// surrounding it, and the keys of the data the template was given.
+type TemplateError struct {
+	Name   string
+	Line   int
+	Column int
+	Source []SourceLine
+	Keys   []string
+	Err    error
+}
+
+// SourceLine is a numbered line of template source.
+type SourceLine struct {
+	Number int
+	Text   string
+}
+
+func (e *TemplateError) Error() string {
+	return e.Err.Error()
+}
+
+// sourcer is implemented by a Templater able to return the source of a
+// template.
+type sourcer interface {
+	templateSource(string) (string, error)
+}
+
+func (t *templater) templateSource(name string) (string, error) {
+	return t.loader.Load(name)
+}
+
+func (t *standard) templateSource(name string) (string, error) {
+	return t.loader.Load(name)
+}
+
+// errorPosition finds the template, line and column of a parse or execution
+// error in the form reported by djinn and the standard template packages.
+var errorPosition = regexp.MustCompile(`template:\s*([^:\s]+):(\d+)(?::(\d+))?`)
+
+// sourceContext is the number of lines shown either side of a failing line.
+const sourceContext = 3
+
+// newTemplateError returns a TemplateError for the named template failing
+// with err when rendered with data.
+func newTemplateError(t Templater, name string, data interface{}, err error) *TemplateError {
+	te := &TemplateError{Name: name, Keys: dataKeys(data), Err: err}
+	if m := errorPosition.FindStringSubmatch(err.Error()); m != nil {
+		te.Name = m[1]
+		te.Line, _ = strconv.Atoi(m[2])
+		te.Column, _ = strconv.Atoi(m[3])
+	}
+	if s, ok := t.(sourcer); ok && te.Line > 0 {
+		if text, err := s.templateSource(te.Name); err == nil {
+			lines := strings.Split(text, "\n")
+			for n := te.Line - sourceContext; n <= te.Line+sourceContext; n++ {
+				if n > 0 && n <= len(lines) {
+					te.Source = append(te.Source, SourceLine{n, lines[n-1]})
+				}
+			}
+		}
+	}
+	return te
+}
+
+// dataKeys returns the sorted keys of map data, or the field names of struct
+// data, given to a template.
+func dataKeys(data interface{}) []string {
+	if td, ok := data.(*templateData); ok {
+		data = td.Data
+	}
+	var keys []string
+	v := reflect.Indirect(reflect.ValueOf(data))
+	switch v.Kind() {
+	case reflect.Map:
+		for _, k := range v.MapKeys() {
+			keys = append(keys, fmt.Sprint(k.Interface()))
+		}
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			if f := v.Type().Field(i); f.PkgPath == "" {
+				keys = append(keys, f.Name)
+			}
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+const debugHtml = `<html>
+<head><title>Template Error</title>
+<style type="text/css">
+html, body {
+font-family: "Roboto", sans-serif;
+color: #333333;
+margin: 0px;
+}
+h1 {
+color: #2b3848;
+background-color: #ffffff;
+padding: 20px;
+border-bottom: 1px dashed #2b3848;
+}
+h2 {
+margin: 20px;
+}
+pre {
+font-size: 1.1em;
+margin: 20px;
+padding: 20px;
+border: 2px solid #2b3848;
+background-color: #ffffff;
+}
+pre .failed {font-weight: bold; background-color: rgba(216,43,43,0.15);}
+</style>
+</head>
+<body>
+<h1>Error in template %s</h1>
+<pre style="font-weight: bold;">%s</pre>
+%s
+<h2>Data keys</h2>
+<pre>%s</pre>
+</body>
+</html>
+`
+
+// DebugPage returns an html page describing the error, for development.
+func (e *TemplateError) DebugPage() string {
+	var src bytes.Buffer
+	if len(e.Source) > 0 {
+		fmt.Fprintf(&src, "<h2>Line %d, column %d</h2>\n<pre>", e.Line, e.Column)
+		for _, l := range e.Source {
+			class := ""
+			if l.Number == e.Line {
+				class = ` class="failed"`
+			}
+			fmt.Fprintf(&src, "<span%s>%4d  %s</span>\n", class, l.Number, html.EscapeString(l.Text))
+		}
+		src.WriteString("</pre>")
+	}
+	keys := "none"
+	if len(e.Keys) > 0 {
+		keys = html.EscapeString(strings.Join(e.Keys, "\n"))
+	}
+	return fmt.Sprintf(debugHtml, html.EscapeString(e.Name), html.EscapeString(e.Error()), src.String(), keys)
+}
+
+func production(s state.State) bool {
+	m, err := s.Call("mode_is", "production")
+	p, ok := m.(bool)
+	return err != nil || !ok || p
+}
+
+// renderTemplate defers rendering the named template to the response of the
+// state until its managers have run. A template that fails to render is
+// recorded as an error of the state and, when nothing has been written to
+// the response, the blueprint 500 status is rendered in production mode, or
+// a page describing the error otherwise.
+func renderTemplate(t Templater, s state.State, template string, data interface{}) error {
+	s.Push(func(ps state.State) {
+		err := RenderTo(t, ps.RWriter(), template, data, ps)
+		if err == nil {
+			return
+		}
+		te := newTemplateError(t, template, data, err)
+		ps.Xrror("%s", xrr.ErrorTypeInternal, te, te)
+		if ps.RWriter().Written() {
+			return
+		}
+		page := te.DebugPage()
+		if production(ps) {
+			if _, err := ps.Call("status", http.StatusInternalServerError); err == nil {
+				return
+			}
+			page = http.StatusText(http.StatusInternalServerError)
+		}
+		w := ps.RWriter()
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(page))
+	})
+	return nil
+}
@@ -0,0 +1,173 @@
+package template
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/flxtilla/cxre/asset"
+	"github.com/flxtilla/cxre/store"
+)
+
+// Discoverer is implemented by a Templater that discovers templates in
+// namespaced roots, and reports where each template was found.
+type Discoverer interface {
+	TemplateNamespace(string, ...string) []string
+	Discover() []Discovered
+}
+
+// Discovered describes a template by the name it is loaded by, the location
+// (a file path or asset name) it is loaded from, and the locations of any
+// templates with the same name it shadows, in order of precedence.
+type Discovered struct {
+	Name     string
+	Location string
+	Shadowed []string
+}
+
+// root is a location templates are found in: a directory, optionally serving
+// the templates of a namespace, or an AssetFS.
+type root struct {
+	namespace string
+	dir       string
+	fs        asset.AssetFS
+}
+
+func (r root) location(name string) string {
+	if r.fs != nil {
+		return name
+	}
+	return filepath.Join(r.dir, filepath.FromSlash(name))
+}
+
+// relative returns the name of a template within the root, and a boolean
+// indicating if the root may hold the template.
+func (r root) relative(name string) (string, bool) {
+	if r.namespace == "" {
+		return name, true
+	}
+	prefix := r.namespace + "/"
+	if !strings.HasPrefix(name, prefix) {
+		return "", false
+	}
+	return strings.TrimPrefix(name, prefix), true
+}
+
+// cleanName returns a slash separated template name that cannot refer to a
+// file outside of a root.
+func cleanName(name string) string {
+	return strings.TrimPrefix(path.Clean("/"+filepath.ToSlash(name)), "/")
+}
+
+func namespaceEntry(namespace, dir string) string {
+	return fmt.Sprintf("%s=%s", namespace, dir)
+}
+
+// TemplateNamespace adds directories to the template root for a namespace,
+// e.g. the templates of an admin blueprint, returning the directories of the
+// namespace. A template named admin/users.html is found in the admin
+// namespace directories before any other directory or asset.
+func (t *templater) TemplateNamespace(namespace string, added ...string) []string {
+	return templateNamespace(t.s, namespace, added...)
+}
+
+func templateNamespace(s store.Store, namespace string, added ...string) []string {
+	namespace = strings.Trim(filepath.ToSlash(namespace), "/")
+	entries := s.List("TEMPLATE_NAMESPACES")
+	if added != nil {
+		for _, dir := range added {
+			entries = doAdd(namespaceEntry(namespace, dir), entries)
+		}
+		s.Add("TEMPLATE_NAMESPACES", strings.Join(entries, ","))
+	}
+	var dirs []string
+	for _, e := range entries {
+		if strings.HasPrefix(e, namespace+"=") {
+			dirs = append(dirs, strings.TrimPrefix(e, namespace+"="))
+		}
+	}
+	return dirs
+}
+
+// roots returns every template root in order of precedence: namespace
+// directories, then the template directories and the asset FSs, with the
+// directories first unless template_precedence is "assets".
+func (l *loader) roots() []root {
+	var ns, dirs, fss []root
+	for _, e := range l.s.List("template_namespaces") {
+		if i := strings.Index(e, "="); i > 0 {
+			ns = append(ns, root{namespace: e[:i], dir: e[i+1:]})
+		}
+	}
+	for _, d := range l.s.List("template_directories") {
+		dirs = append(dirs, root{dir: d})
+	}
+	for _, fs := range l.a.ListAssetFS() {
+		fss = append(fss, root{fs: fs})
+	}
+	if l.s.String("template_precedence") == "assets" {
+		return append(append(ns, fss...), dirs...)
+	}
+	return append(append(ns, dirs...), fss...)
+}
+
+// rootNames returns the names of the templates held by a root.
+func (l *loader) rootNames(r root) []string {
+	var ret []string
+	if r.fs != nil {
+		for _, f := range r.fs.AssetNames() {
+			if l.ValidFileExtension(filepath.Ext(f)) {
+				ret = append(ret, f)
+			}
+		}
+		return ret
+	}
+	filepath.Walk(r.dir, func(p string, fi os.FileInfo, err error) error {
+		if err != nil || fi.IsDir() || !l.ValidFileExtension(filepath.Ext(p)) {
+			return nil
+		}
+		rel, err := filepath.Rel(r.dir, p)
+		if err != nil {
+			return nil
+		}
+		name := filepath.ToSlash(rel)
+		if r.namespace != "" {
+			name = r.namespace + "/" + name
+		}
+		ret = append(ret, name)
+		return nil
+	})
+	return ret
+}
+
+// Discover returns every template found, sorted by name, with the location
+// it loads from and the locations it shadows.
+func (t *templater) Discover() []Discovered {
+	return t.loader.discover()
+}
+
+func (l *loader) discover() []Discovered {
+	found := make(map[string]*Discovered)
+	var names []string
+	for _, r := range l.roots() {
+		for _, name := range l.rootNames(r) {
+			rel, _ := r.relative(name)
+			loc := r.location(rel)
+			if d, ok := found[name]; ok {
+				d.Shadowed = append(d.Shadowed, loc)
+				continue
+			}
+			found[name] = &Discovered{Name: name, Location: loc}
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	ret := make([]Discovered, 0, len(names))
+	for _, n := range names {
+		ret = append(ret, *found[n])
+	}
+	return ret
+}
@@ -1,23 +1,31 @@
 package template
 
 import (
-	"fmt"
 	"io/ioutil"
 	"os"
-	"path/filepath"
+	"path"
+	"sync"
 
+	"github.com/flxtilla/cxre/asset"
+	"github.com/flxtilla/cxre/store"
 	"github.com/flxtilla/cxre/xrr"
 )
 
 type loader struct {
-	*templater
+	s              store.Store
+	a              asset.Assets
 	FileExtensions []string
+	mu             sync.Mutex
+	mode           CacheMode
+	cache          map[string]*source
 }
 
-func newLoader(t *templater) *loader {
+func newLoader(s store.Store, a asset.Assets, extensions ...string) *loader {
 	return &loader{
-		templater:      t,
-		FileExtensions: []string{".html", ".dji"},
+		s:              s,
+		a:              a,
+		FileExtensions: extensions,
+		cache:          make(map[string]*source),
 	}
 }
 
@@ -32,50 +40,97 @@ func (l *loader) ValidFileExtension(ext string) bool {
 	return false
 }
 
-func (l *loader) assetTemplates() []string {
-	var ret []string
-	for _, assetfs := range l.a.ListAssetFS() {
-		for _, f := range assetfs.AssetNames() {
-			if l.ValidFileExtension(filepath.Ext(f)) {
-				ret = append(ret, f)
-			}
-		}
-	}
-	return ret
-}
-
-// ListTemplates lists templates in the Loader.
+// ListTemplates lists the location of every template in the Loader, being
+// the file path or asset name of the template loaded for each name.
 func (l *loader) ListTemplates() []string {
 	var ret []string
-	for _, p := range l.s.List("template_directories") {
-		files, _ := ioutil.ReadDir(p)
-		for _, f := range files {
-			if l.ValidFileExtension(filepath.Ext(f.Name())) {
-				ret = append(ret, fmt.Sprintf("%s/%s", p, f.Name()))
-			}
-		}
+	for _, d := range l.discover() {
+		ret = append(ret, d.Location)
 	}
-	ret = append(ret, l.assetTemplates()...)
 	return ret
 }
 
 var TemplateDoesNotExist = xrr.NewXrror("Template %s does not exist.").Out
 
-// Load a template by string name from the flotilla Loader.
+// Load a template by string name from the flotilla Loader. Loaded templates
+// are cached; in Development mode a template modified on disk is read again.
 func (l *loader) Load(name string) (string, error) {
-	for _, p := range l.s.List("template_directories") {
-		f := filepath.Join(p, name)
-		if l.ValidFileExtension(filepath.Ext(f)) {
-			if _, err := os.Stat(f); err == nil {
-				file, err := os.Open(f)
-				r, err := ioutil.ReadAll(file)
-				return string(r), err
-			}
-			if r, err := l.a.GetAsset(name); err == nil {
-				r, err := ioutil.ReadAll(r)
-				return string(r), err
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if src, ok := l.cache[name]; ok && (l.mode == Production || !src.modified()) {
+		return src.text, nil
+	}
+	src, err := l.read(name)
+	if err != nil {
+		return "", err
+	}
+	l.cache[name] = src
+	return src.text, nil
+}
+
+// read reads the named template from the first root holding it.
+func (l *loader) read(name string) (*source, error) {
+	name = cleanName(name)
+	if !l.ValidFileExtension(path.Ext(name)) {
+		return nil, TemplateDoesNotExist(name)
+	}
+	for _, r := range l.roots() {
+		rel, ok := r.relative(name)
+		if !ok {
+			continue
+		}
+		if r.fs != nil {
+			if b, err := r.fs.Asset(rel); err == nil {
+				return &source{text: string(b)}, nil
 			}
+			continue
+		}
+		f := r.location(rel)
+		if fi, err := os.Stat(f); err == nil && !fi.IsDir() {
+			b, err := ioutil.ReadFile(f)
+			return &source{f, fi.ModTime(), string(b)}, err
 		}
 	}
-	return "", TemplateDoesNotExist(name)
+	return nil, TemplateDoesNotExist(name)
+}
+
+// modified returns a boolean indicating if any cached template has been
+// modified on disk since it was loaded.
+func (l *loader) modified() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, src := range l.cache {
+		if src.modified() {
+			return true
+		}
+	}
+	return false
+}
+
+func (l *loader) cacheMode() CacheMode {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.mode
+}
+
+func (l *loader) setCacheMode(m CacheMode) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.mode = m
+}
+
+// flush empties the template cache.
+func (l *loader) flush() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.cache = make(map[string]*source)
+}
+
+// names returns the name of every template.
+func (l *loader) names() []string {
+	var ret []string
+	for _, d := range l.discover() {
+		ret = append(ret, d.Name)
+	}
+	return ret
 }
@@ -0,0 +1,39 @@
+package template
+
+import (
+	"bytes"
+	"io"
+
+	"github.com/flxtilla/cxre/state"
+)
+
+// RenderTo renders the named template with data to w outside of a request,
+// e.g. for an email or a PDF. Data is given to the template as for
+// RenderTemplate, with the optional state, e.g. one from state.Detached, for
+// template functions that need one. Nothing is written to w when rendering
+// fails.
+func RenderTo(t Templater, w io.Writer, name string, data interface{}, s ...state.State) error {
+	var b bytes.Buffer
+	if err := t.Render(&b, name, NewTemplateData(firstState(s), data)); err != nil {
+		return err
+	}
+	_, err := b.WriteTo(w)
+	return err
+}
+
+// RenderString renders the named template with data to a string outside of
+// a request, as for RenderTo.
+func RenderString(t Templater, name string, data interface{}, s ...state.State) (string, error) {
+	var b bytes.Buffer
+	if err := RenderTo(t, &b, name, data, s...); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+func firstState(s []state.State) state.State {
+	if len(s) > 0 {
+		return s[0]
+	}
+	return nil
+}
@@ -0,0 +1,255 @@
+package template
+
+import (
+	htmltemplate "html/template"
+	"io"
+	"path"
+	"regexp"
+	"sync"
+	texttemplate "text/template"
+
+	"github.com/flxtilla/cxre/asset"
+	"github.com/flxtilla/cxre/state"
+	"github.com/flxtilla/cxre/store"
+	"github.com/flxtilla/cxre/xrr"
+)
+
+var TemplateLayoutCycle = xrr.NewXrror("Template %s extends itself through %s.").Out
+
+// executor executes a named template of a parsed template set.
+type executor interface {
+	ExecuteTemplate(io.Writer, string, interface{}) error
+}
+
+// file is the name and text of a template parsed into a set.
+type file struct {
+	name, text string
+}
+
+// parser parses files, in order, into a single template set with functions.
+type parser func(map[string]interface{}, []file) (executor, error)
+
+func parseHTML(fns map[string]interface{}, files []file) (executor, error) {
+	var set *htmltemplate.Template
+	for _, f := range files {
+		var t *htmltemplate.Template
+		if set == nil {
+			set = htmltemplate.New(f.name).Funcs(fns)
+			t = set
+		} else {
+			t = set.New(f.name)
+		}
+		if _, err := t.Parse(f.text); err != nil {
+			return nil, err
+		}
+	}
+	return set, nil
+}
+
+func parseText(fns map[string]interface{}, files []file) (executor, error) {
+	var set *texttemplate.Template
+	for _, f := range files {
+		var t *texttemplate.Template
+		if set == nil {
+			set = texttemplate.New(f.name).Funcs(fns)
+			t = set
+		} else {
+			t = set.New(f.name)
+		}
+		if _, err := t.Parse(f.text); err != nil {
+			return nil, err
+		}
+	}
+	return set, nil
+}
+
+// compiled is a parsed template set, and the template executed to render it.
+type compiled struct {
+	set   executor
+	entry string
+}
+
+type standard struct {
+	s      store.Store
+	a      asset.Assets
+	loader *loader
+	parse  parser
+	mu     sync.Mutex
+	sets   map[string]*compiled
+	*functions
+}
+
+// HTMLTemplater returns a Templater rendering templates with html/template,
+// loading .html and .tmpl templates from the template directories and assets.
+//
+// A template may extend a layout by beginning with a comment naming it, e.g.
+// {{/* extends "layout.html" */}}, and overriding the blocks of the layout
+// with define. A template defining blocks without naming a layout extends
+// the template_base setting, if it is set. Templates included by name with
+// template or block are loaded and parsed with the template including them.
+func HTMLTemplater(s store.Store, a asset.Assets) Templater {
+	return newStandard(s, a, parseHTML, ".html", ".tmpl")
+}
+
+// TextTemplater returns a Templater rendering templates with text/template,
+// e.g. for email and other plain text, loading .txt, .text and .tmpl
+// templates. Layouts and included templates are as for HTMLTemplater.
+func TextTemplater(s store.Store, a asset.Assets) Templater {
+	return newStandard(s, a, parseText, ".txt", ".text", ".tmpl")
+}
+
+func newStandard(s store.Store, a asset.Assets, p parser, extensions ...string) *standard {
+	store.MustRegister(s, templateSettings...)
+	t := &standard{
+		s:      s,
+		a:      a,
+		loader: newLoader(s, a, extensions...),
+		parse:  p,
+		sets:   make(map[string]*compiled),
+	}
+	t.functions = &functions{apply: func(map[string]interface{}) {
+		t.flushSets()
+	}}
+	return t
+}
+
+func (t *standard) TemplateDirs(added ...string) []string {
+	return templateDirs(t.s, added...)
+}
+
+func (t *standard) TemplateNamespace(namespace string, added ...string) []string {
+	return templateNamespace(t.s, namespace, added...)
+}
+
+func (t *standard) ListTemplates() []string {
+	return t.loader.ListTemplates()
+}
+
+func (t *standard) Discover() []Discovered {
+	return t.loader.discover()
+}
+
+// CacheMode returns the CacheMode of the Templater.
+func (t *standard) CacheMode() CacheMode {
+	return t.loader.cacheMode()
+}
+
+// SetCacheMode sets the CacheMode of the Templater, e.g. to the ModeFor the
+// app mode.
+func (t *standard) SetCacheMode(m CacheMode) {
+	t.loader.setCacheMode(m)
+}
+
+// Precompile loads and parses every template with its layouts and included
+// templates, returning the first that cannot be read or parsed.
+func (t *standard) Precompile() error {
+	for _, name := range t.loader.names() {
+		if _, err := t.compiled(name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Flush discards every cached template and parsed template set.
+func (t *standard) Flush() {
+	t.loader.flush()
+	t.flushSets()
+}
+
+func (t *standard) flushSets() {
+	t.mu.Lock()
+	t.sets = make(map[string]*compiled)
+	t.mu.Unlock()
+}
+
+// Render renders the named template to w. In Development mode, templates
+// modified on disk since they were cached are flushed and parsed again.
+func (t *standard) Render(w io.Writer, name string, data interface{}) error {
+	if t.CacheMode() == Development && t.loader.modified() {
+		t.Flush()
+	}
+	c, err := t.compiled(name)
+	if err != nil {
+		return err
+	}
+	return c.set.ExecuteTemplate(w, c.entry, data)
+}
+
+func (t *standard) RenderTemplate(s state.State, template string, data interface{}) error {
+	return renderTemplate(t, s, template, data)
+}
+
+func (t *standard) compiled(name string) (*compiled, error) {
+	t.mu.Lock()
+	c, ok := t.sets[name]
+	t.mu.Unlock()
+	if ok {
+		return c, nil
+	}
+	files, err := t.files(name)
+	if err != nil {
+		return nil, err
+	}
+	set, err := t.parse(t.templateFunctions, files)
+	if err != nil {
+		return nil, TemplateSyntaxError(name, err)
+	}
+	c = &compiled{set, files[0].name}
+	t.mu.Lock()
+	t.sets[name] = c
+	t.mu.Unlock()
+	return c, nil
+}
+
+var (
+	extendsComment = regexp.MustCompile(`^\s*{{-?\s*/\*\s*extends\s+"([^"]+)"\s*\*/\s*-?}}`)
+	defines        = regexp.MustCompile(`{{-?\s*define\s`)
+	includes       = regexp.MustCompile(`{{-?\s*(?:template|block)\s+"([^"]+)"`)
+)
+
+// files returns the files parsed to render the named template: its layouts,
+// outermost first, the template, then every template they include by name.
+func (t *standard) files(name string) ([]file, error) {
+	var files []file
+	seen := make(map[string]bool)
+	for n := name; n != ""; {
+		if seen[n] {
+			return nil, TemplateLayoutCycle(name, n)
+		}
+		seen[n] = true
+		text, err := t.loader.Load(n)
+		if err != nil {
+			return nil, err
+		}
+		files = append([]file{{n, text}}, files...)
+		n = t.layout(n, text)
+	}
+	for i := 0; i < len(files); i++ {
+		for _, m := range includes.FindAllStringSubmatch(files[i].text, -1) {
+			inc := m[1]
+			if seen[inc] || !t.loader.ValidFileExtension(path.Ext(inc)) {
+				continue
+			}
+			seen[inc] = true
+			text, err := t.loader.Load(inc)
+			if err != nil {
+				return nil, err
+			}
+			files = append(files, file{inc, text})
+		}
+	}
+	return files, nil
+}
+
+// layout returns the name of the layout a template extends, if any.
+func (t *standard) layout(name, text string) string {
+	if m := extendsComment.FindStringSubmatch(text); m != nil {
+		return m[1]
+	}
+	base := t.s.String("template_base")
+	if base != "" && base != name && defines.MatchString(text) {
+		return base
+	}
+	return ""
+}
@@ -28,6 +28,12 @@ func NewTemplateData(s state.State, in interface{}) TemplateData {
 	return ret
 }
 
+// HasState returns a boolean indicating if the data holds the state of a
+// request, being false for data rendered outside of a request.
+func (t *templateData) HasState() bool {
+	return t.State != nil
+}
+
 //func (t templateData) HTML(name string) template.HTML {
 //	res, err := t.State.Call(name)
 //
@@ -1,18 +1,27 @@
 package template
 
 import (
+	"bytes"
 	"fmt"
 	"html/template"
 	"io"
+	"io/ioutil"
+	"net/http"
 	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/flxtilla/app"
+	"github.com/flxtilla/cxre/asset"
+	"github.com/flxtilla/cxre/engine"
 	"github.com/flxtilla/cxre/extension"
+	"github.com/flxtilla/cxre/log"
 	"github.com/flxtilla/cxre/state"
+	"github.com/flxtilla/cxre/store"
 	"github.com/flxtilla/cxre/template/resources"
 	"github.com/flxtilla/txst"
 )
@@ -138,8 +147,8 @@ func TestDefaultTemplating(t *testing.T) {
 				`returned STRING`,
 				`<div>returned HTML</div>`,
 				`[TEST_FLASH_ONE TEST_FLASH_TWO]`,
-				// `/template?value1%3Dadditional`,
-				// `Unable to get url for route \does\not\exist\p\s\get with params [param /a/splat/fragment].`,
+				`/template?value1=additional`,
+				`Unable to get url for route \does\not\exist\p\s\get with params [param /a/splat/fragment].`,
 				// `SET_IN_CTX`,
 			}
 
@@ -229,3 +238,325 @@ func TestTemplatr(t *testing.T) {
 	)
 	txst.SimplePerformer(t, a, exp).Perform()
 }
+
+func writeTemplate(t *testing.T, path, text string, mod time.Time) {
+	if err := ioutil.WriteFile(path, []byte(text), 0644); err != nil {
+		t.Fatal(err)
+	}
+	os.Chtimes(path, mod, mod)
+}
+
+func TestCacheModes(t *testing.T) {
+	dir, err := ioutil.TempDir("", "templates")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	page := filepath.Join(dir, "page.html")
+	now := time.Now()
+	writeTemplate(t, page, "one", now.Add(-time.Hour))
+
+	s := store.New()
+	s.Add("template_directories", dir)
+	tr := DefaultTemplater(s, asset.New()).(*templater)
+	render := func() string {
+		var b bytes.Buffer
+		if err := tr.Render(&b, "page.html", nil); err != nil {
+			t.Fatalf("Render error: %s", err)
+		}
+		return b.String()
+	}
+
+	if tr.CacheMode() != Development || render() != "one" {
+		t.Fatal("Development mode did not render the template")
+	}
+	writeTemplate(t, page, "two", now.Add(-time.Minute))
+	if v := render(); v != "two" {
+		t.Errorf("Development mode rendered %q after the template was modified", v)
+	}
+
+	for mode, expected := range map[string]CacheMode{"production": Production, "development": Development} {
+		ext := extension.New("Mode_Extension", extension.NewFunction("mode_is", func(is string) bool {
+			return is == mode
+		}))
+		st := state.New(ext, engine.NewResult(200, nil, nil, false), log.New(ioutil.Discard, log.LInfo, log.DefaultNullFormatter()))
+		if m := ModeFor(st); m != expected {
+			t.Errorf("ModeFor %s was %s", mode, m)
+		}
+	}
+	if err := New(tr).SetMode(Production); err != nil || tr.CacheMode() != Production {
+		t.Fatalf("SetMode error: %v", err)
+	}
+	writeTemplate(t, page, "three", now)
+	if v := render(); v != "two" {
+		t.Errorf("Production mode rendered %q after the template was modified", v)
+	}
+
+	writeTemplate(t, filepath.Join(dir, "broken.html"), "{{ if .Title }}unclosed", now)
+	if err := tr.Precompile(); err == nil || !strings.Contains(err.Error(), "broken.html") {
+		t.Errorf("Precompile of a broken template returned %v", err)
+	}
+	if err := New(tr).SetMode(Production); err == nil {
+		t.Error("SetMode of Production with a broken template did not return an error")
+	}
+}
+
+type testAssetFS map[string]string
+
+func (fs testAssetFS) Asset(name string) ([]byte, error) {
+	if v, ok := fs[name]; ok {
+		return []byte(v), nil
+	}
+	return nil, os.ErrNotExist
+}
+
+func (fs testAssetFS) AssetHttp(name string) (http.File, error) {
+	return nil, os.ErrNotExist
+}
+
+func (fs testAssetFS) AssetDir(name string) ([]string, error) {
+	return nil, os.ErrNotExist
+}
+
+func (fs testAssetFS) AssetNames() []string {
+	var names []string
+	for k := range fs {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func TestDiscovery(t *testing.T) {
+	dir, err := ioutil.TempDir("", "templates")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	appDir, adminDir := filepath.Join(dir, "app"), filepath.Join(dir, "admin")
+	now := time.Now()
+	os.MkdirAll(filepath.Join(appDir, "admin"), 0755)
+	os.MkdirAll(filepath.Join(appDir, "partials"), 0755)
+	os.MkdirAll(adminDir, 0755)
+	writeTemplate(t, filepath.Join(appDir, "layout.html"), "app layout", now)
+	writeTemplate(t, filepath.Join(appDir, "partials", "nav.html"), "app nav", now)
+	writeTemplate(t, filepath.Join(appDir, "admin", "users.html"), "app users", now)
+	writeTemplate(t, filepath.Join(adminDir, "users.html"), "admin users", now)
+
+	s := store.New()
+	tr := DefaultTemplater(s, asset.New(testAssetFS{"layout.html": "asset layout", "asset.html": "asset"})).(*templater)
+	tr.TemplateDirs(appDir)
+	if dirs := tr.TemplateNamespace("admin", adminDir); len(dirs) != 1 || dirs[0] != adminDir {
+		t.Errorf("TemplateNamespace returned %v", dirs)
+	}
+
+	render := func(name string) string {
+		var b bytes.Buffer
+		if err := tr.Render(&b, name, nil); err != nil {
+			t.Fatalf("Render %s error: %s", name, err)
+		}
+		return b.String()
+	}
+	for name, expected := range map[string]string{
+		"layout.html":       "app layout",
+		"partials/nav.html": "app nav",
+		"admin/users.html":  "admin users",
+		"asset.html":        "asset",
+	} {
+		if v := render(name); v != expected {
+			t.Errorf("Render %s rendered %q, not %q", name, v, expected)
+		}
+	}
+	if src, err := tr.loader.read("../layout.html"); err != nil || src.text != "app layout" {
+		t.Errorf("read of a cleaned name returned %v", err)
+	}
+	if _, err := tr.loader.read("../app/layout.html"); err == nil {
+		t.Error("read of a name outside of the template roots did not return an error")
+	}
+
+	var found []string
+	for _, d := range tr.Discover() {
+		found = append(found, fmt.Sprintf("%s:%d", d.Name, len(d.Shadowed)))
+		if d.Name == "admin/users.html" && (d.Location != filepath.Join(adminDir, "users.html") || d.Shadowed[0] != filepath.Join(appDir, "admin", "users.html")) {
+			t.Errorf("Discover found %+v", d)
+		}
+		if d.Name == "layout.html" && (len(d.Shadowed) != 1 || d.Shadowed[0] != "layout.html") {
+			t.Errorf("Discover found %+v", d)
+		}
+	}
+	if strings.Join(found, ",") != "admin/users.html:1,asset.html:0,layout.html:1,partials/nav.html:0" {
+		t.Errorf("Discover found %v", found)
+	}
+
+	s.Add("template_precedence", "assets")
+	tr.Flush()
+	if v := render("layout.html"); v != "asset layout" {
+		t.Errorf("Render with asset precedence rendered %q", v)
+	}
+}
+
+func TestStandardTemplaters(t *testing.T) {
+	dir, err := ioutil.TempDir("", "templates")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	now := time.Now()
+	writeTemplate(t, filepath.Join(dir, "base.html"), `<title>{{ block "title" . }}Base{{ end }}</title>{{ block "content" . }}{{ end }}`, now)
+	writeTemplate(t, filepath.Join(dir, "layout.html"), `{{/* extends "base.html" */}}{{ define "content" }}<main>{{ block "main" . }}{{ end }}</main>{{ template "nav.html" . }}{{ end }}`, now)
+	writeTemplate(t, filepath.Join(dir, "page.html"), `{{/* extends "layout.html" */}}{{ define "title" }}{{ upper .Title }}{{ end }}{{ define "main" }}{{ .Body }}{{ end }}`, now)
+	writeTemplate(t, filepath.Join(dir, "simple.html"), `{{ define "title" }}Simple{{ end }}`, now)
+	writeTemplate(t, filepath.Join(dir, "loop.html"), `{{/* extends "loop.html" */}}`, now)
+	writeTemplate(t, filepath.Join(dir, "nav.html"), `<nav></nav>`, now)
+	writeTemplate(t, filepath.Join(dir, "email.txt"), `Hello {{ upper .Title }}, {{ .Body }}`, now)
+
+	s := store.New()
+	s.Add("template_directories", dir)
+	s.Add("template_base", "base.html")
+	fns := map[string]interface{}{"upper": strings.ToUpper}
+	render := func(tr Templater, name string) (string, error) {
+		var b bytes.Buffer
+		err := tr.Render(&b, name, map[string]string{"Title": "title", "Body": "<b>body</b>"})
+		return b.String(), err
+	}
+
+	ht := HTMLTemplater(s, asset.New(testAssetFS{"asset.html": `{{ template "nav.html" }}asset`}))
+	ht.AddTemplateFunctions(fns)
+	ht.SetTemplateFunctions()
+	for name, expected := range map[string]string{
+		"page.html":   "<title>TITLE</title><main>&lt;b&gt;body&lt;/b&gt;</main><nav></nav>",
+		"simple.html": "<title>Simple</title>",
+		"asset.html":  "<nav></nav>asset",
+	} {
+		if v, err := render(ht, name); err != nil || v != expected {
+			t.Errorf("HTMLTemplater rendered %s as %q, %v", name, v, err)
+		}
+	}
+	if _, err := render(ht, "loop.html"); err == nil {
+		t.Error("HTMLTemplater rendered a template extending itself")
+	}
+	if _, err := render(ht, "email.txt"); err == nil {
+		t.Error("HTMLTemplater rendered a text template")
+	}
+
+	tt := TextTemplater(s, asset.New())
+	tt.AddTemplateFunctions(fns)
+	tt.SetTemplateFunctions()
+	if v, err := render(tt, "email.txt"); err != nil || v != "Hello TITLE, <b>body</b>" {
+		t.Errorf("TextTemplater rendered %q, %v", v, err)
+	}
+	if ls := tt.ListTemplates(); len(ls) != 1 || filepath.Base(ls[0]) != "email.txt" {
+		t.Errorf("TextTemplater listed %v", ls)
+	}
+}
+
+func TestRenderTo(t *testing.T) {
+	dir, err := ioutil.TempDir("", "templates")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	writeTemplate(t, filepath.Join(dir, "email.txt"), `Dear {{ .Data.Name }}`, time.Now())
+	writeTemplate(t, filepath.Join(dir, "broken.txt"), `before {{ .Data.Name.Missing }}`, time.Now())
+
+	s := store.New()
+	s.Add("template_directories", dir)
+	ts := New(TextTemplater(s, asset.New()))
+	if v, err := ts.RenderString("email.txt", map[string]interface{}{"Name": "Reader"}); err != nil || v != "Dear Reader" {
+		t.Errorf("RenderString rendered %q, %v", v, err)
+	}
+	var b bytes.Buffer
+	if err := ts.RenderTo(&b, "broken.txt", map[string]interface{}{"Name": "Reader"}); err == nil || b.Len() != 0 {
+		t.Errorf("RenderTo of a failing template wrote %q, %v", b.String(), err)
+	}
+	if err := ts.RenderTo(&b, "missing.txt", nil); err == nil {
+		t.Error("RenderTo of a missing template did not return an error")
+	}
+	if NewTemplateData(nil, nil).(*templateData).HasState() {
+		t.Error("template data rendered outside of a request has a state")
+	}
+}
+
+func TestTemplateError(t *testing.T) {
+	dir, err := ioutil.TempDir("", "templates")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	writeTemplate(t, filepath.Join(dir, "page.html"), "<h1>\n{{ .Title }}\n<p>{{ .Title.Missing }}</p>\n</h1>", time.Now())
+	writeTemplate(t, filepath.Join(dir, "syntax.html"), "<h1>\n{{ if .Title }}\n</h1>", time.Now())
+
+	data := map[string]interface{}{"Title": "title", "Body": "<b>"}
+	for name, templater := range map[string]func(store.Store, asset.Assets) Templater{
+		"html":  HTMLTemplater,
+		"djinn": DefaultTemplater,
+	} {
+		s := store.New()
+		s.Add("template_directories", dir)
+		tr := templater(s, asset.New())
+
+		err := tr.Render(ioutil.Discard, "page.html", data)
+		te := newTemplateError(tr, "page.html", data, err)
+		if te.Name != "page.html" || te.Line != 3 || te.Column == 0 || len(te.Source) != 4 || te.Source[2].Text != "<p>{{ .Title.Missing }}</p>" {
+			t.Errorf("%s TemplateError was %+v", name, te)
+		}
+		if strings.Join(te.Keys, ",") != "Body,Title" {
+			t.Errorf("%s TemplateError keys were %v", name, te.Keys)
+		}
+		page := te.DebugPage()
+		for _, expected := range []string{"Error in template page.html", `<span class="failed">   3  &lt;p&gt;{{ .Title.Missing }}&lt;/p&gt;</span>`, "Body\nTitle"} {
+			if !strings.Contains(page, expected) {
+				t.Errorf("%s DebugPage does not contain %q:\n%s", name, expected, page)
+			}
+		}
+
+		err = tr.Render(ioutil.Discard, "syntax.html", data)
+		if te := newTemplateError(tr, "syntax.html", struct{ Title, private string }{}, err); te.Line == 0 || strings.Join(te.Keys, ",") != "Title" {
+			t.Errorf("%s TemplateError of a syntax error was %+v", name, te)
+		}
+	}
+}
+
+// deferringState is a State recording the functions pushed to it, to run
+// them as the state would after its managers.
+type deferringState struct {
+	state.State
+	deferred []state.Manage
+}
+
+func (s *deferringState) Push(fn state.Manage) {
+	s.deferred = append(s.deferred, fn)
+}
+
+func TestRenderTemplateError(t *testing.T) {
+	dir, err := ioutil.TempDir("", "templates")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	writeTemplate(t, filepath.Join(dir, "page.html"), "<p>{{ .Title.Missing }}</p>", time.Now())
+	s := store.New()
+	s.Add("template_directories", dir)
+	tr := HTMLTemplater(s, asset.New())
+
+	ext := extension.New("Mode_Extension", extension.NewFunction("mode_is", func(_ state.State, is string) bool {
+		return is == "development"
+	}))
+	st := &deferringState{State: state.New(ext, engine.NewResult(200, nil, nil, false), log.New(ioutil.Discard, log.LInfo, log.DefaultNullFormatter()))}
+	w := httptest.NewRecorder()
+	st.Reset(httptest.NewRequest("GET", "/page", nil), w, nil)
+	if err := tr.RenderTemplate(st, "page.html", map[string]interface{}{"Title": "title"}); err != nil {
+		t.Errorf("RenderTemplate returned %v, rather than deferring the error", err)
+	}
+	if len(st.deferred) != 1 || len(st.Errors()) != 0 || w.Body.Len() != 0 {
+		t.Fatalf("RenderTemplate rendered before the managers ran: %v, %q", st.Errors(), w.Body.String())
+	}
+
+	st.deferred[0](st)
+	if len(st.Errors()) != 1 {
+		t.Errorf("rendering recorded errors %v", st.Errors())
+	}
+	if w.Code != http.StatusInternalServerError || !strings.Contains(w.Body.String(), "Error in template page.html") {
+		t.Errorf("rendering served %d %q", w.Code, w.Body.String())
+	}
+}
@@ -3,6 +3,7 @@ package template
 import (
 	"io"
 	"strings"
+	"sync"
 
 	"github.com/flxtilla/cxre/asset"
 	"github.com/flxtilla/cxre/state"
@@ -11,6 +12,11 @@ import (
 	"github.com/thrisp/djinn"
 )
 
+// Templater lists, loads and renders templates. Render returns any error
+// rendering a template. RenderTemplate defers rendering to the response of a
+// state until its managers have run, and so returns before the template is
+// rendered: a failure is recorded as an error of the state and answered with
+// a 500 status or, outside production mode, a page describing it.
 type Templater interface {
 	TemplateDirs(...string) []string
 	ListTemplates() []string
@@ -22,22 +28,123 @@ type Templater interface {
 
 type templater struct {
 	*djinn.Djinn
-	s store.Store
-	a asset.Assets
+	s      store.Store
+	a      asset.Assets
+	loader *loader
+	mu     sync.RWMutex
+	parsed map[string]*djinn.Template
 	*functions
 }
 
+var templateSettings = []store.Setting{
+	{
+		Name:        "template_directories",
+		Type:        store.TypeList,
+		Description: "Directories templates are loaded from.",
+		Subsystem:   "template",
+	},
+	{
+		Name:        "template_namespaces",
+		Type:        store.TypeList,
+		Description: "Namespaced template directories, as namespace=directory.",
+		Subsystem:   "template",
+	},
+	{
+		Name:        "template_base",
+		Description: "Base template extended by html and text templates defining blocks.",
+		Subsystem:   "template",
+	},
+	{
+		Name:        "template_precedence",
+		Default:     "disk",
+		Choices:     []string{"disk", "assets"},
+		Description: "Whether templates on disk or in assets take precedence.",
+		Subsystem:   "template",
+	},
+}
+
 func DefaultTemplater(s store.Store, a asset.Assets) Templater {
+	store.MustRegister(s, templateSettings...)
 	t := &templater{
 		Djinn: djinn.Empty(),
 		s:     s,
 		a:     a,
 	}
-	t.functions = &functions{t, false, nil}
-	t.AddConfig(djinn.Loaders(newLoader(t)))
+	t.functions = &functions{apply: func(fns map[string]interface{}) {
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		t.AddConfig(djinn.TemplateFunctions(fns))
+		t.parsed = nil
+	}}
+	t.loader = newLoader(s, a, ".html", ".dji")
+	t.AddConfig(djinn.Loaders(t.loader))
 	return t
 }
 
+// CacheMode returns the CacheMode of the Templater.
+func (t *templater) CacheMode() CacheMode {
+	return t.loader.cacheMode()
+}
+
+// SetCacheMode sets the CacheMode of the Templater, e.g. to the ModeFor the
+// app mode.
+func (t *templater) SetCacheMode(m CacheMode) {
+	t.loader.setCacheMode(m)
+}
+
+// Precompile fetches every template from the Djinn rendering them, caching
+// the parsed templates and returning the first template that cannot be read
+// or parsed.
+func (t *templater) Precompile() error {
+	t.mu.RLock()
+	d := t.Djinn
+	t.mu.RUnlock()
+	parsed := make(map[string]*djinn.Template)
+	for _, name := range t.loader.names() {
+		tmpl, err := d.Fetch(name)
+		if err != nil {
+			return TemplateSyntaxError(name, err)
+		}
+		parsed[name] = tmpl
+	}
+	t.mu.Lock()
+	if t.Djinn == d {
+		t.parsed = parsed
+	}
+	t.mu.Unlock()
+	return nil
+}
+
+// Flush discards every cached and parsed template, so that each is loaded
+// and parsed again on the next render.
+func (t *templater) Flush() {
+	t.loader.flush()
+	d := djinn.Empty()
+	d.AddConfig(djinn.Loaders(t.loader))
+	if t.functions.set {
+		d.AddConfig(djinn.TemplateFunctions(t.templateFunctions))
+	}
+	t.mu.Lock()
+	t.Djinn, t.parsed = d, nil
+	t.mu.Unlock()
+}
+
+// Render renders the named template to w, from the templates parsed by
+// Precompile where it was called. In Development mode, templates modified
+// on disk since they were cached are flushed and parsed again.
+func (t *templater) Render(w io.Writer, name string, data interface{}) error {
+	if t.CacheMode() == Development && t.loader.modified() {
+		t.Flush()
+	}
+	t.mu.RLock()
+	d, tmpl := t.Djinn, t.parsed[name]
+	t.mu.RUnlock()
+	if tmpl != nil {
+		return tmpl.Execute(w, data)
+	}
+	return d.Render(w, name, data)
+}
+
 func doAdd(s string, ss []string) []string {
 	if isAppendable(s, ss) {
 		ss = append(ss, s)
@@ -55,19 +162,26 @@ func isAppendable(s string, ss []string) bool {
 }
 
 func (t *templater) TemplateDirs(added ...string) []string {
-	dirs := t.s.List("TEMPLATE_DIRECTORIES")
+	return templateDirs(t.s, added...)
+}
+
+func templateDirs(s store.Store, added ...string) []string {
+	dirs := s.List("TEMPLATE_DIRECTORIES")
 	if added != nil {
 		for _, add := range added {
 			dirs = doAdd(add, dirs)
 		}
-		t.s.Add("TEMPLATE_DIRECTORIES", strings.Join(dirs, ","))
+		s.Add("TEMPLATE_DIRECTORIES", strings.Join(dirs, ","))
 	}
 	return dirs
 }
 
 func (t *templater) ListTemplates() []string {
+	t.mu.RLock()
+	d := t.Djinn
+	t.mu.RUnlock()
 	var ret []string
-	for _, l := range t.Djinn.GetLoaders() {
+	for _, l := range d.GetLoaders() {
 		ts := l.ListTemplates()
 		ret = append(ret, ts...)
 	}
@@ -75,7 +189,7 @@ func (t *templater) ListTemplates() []string {
 }
 
 type functions struct {
-	t                 *templater
+	apply             func(map[string]interface{})
 	set               bool
 	templateFunctions map[string]interface{}
 }
@@ -88,7 +202,9 @@ func addTplFunc(f *functions, name string, fn interface{}) {
 }
 
 func (f *functions) SetTemplateFunctions() {
-	f.t.AddConfig(djinn.TemplateFunctions(f.templateFunctions))
+	if f.apply != nil {
+		f.apply(f.templateFunctions)
+	}
 	f.set = true
 }
 
@@ -105,9 +221,5 @@ func (f *functions) AddTemplateFunctions(fns map[string]interface{}) error {
 }
 
 func (t *templater) RenderTemplate(s state.State, template string, data interface{}) error {
-	s.Push(func(ps state.State) {
-		td := NewTemplateData(ps, data)
-		t.Render(ps.RWriter(), template, td)
-	})
-	return nil
+	return renderTemplate(t, s, template, data)
 }
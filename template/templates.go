@@ -1,8 +1,18 @@
 package template
 
+import (
+	"io"
+
+	"github.com/flxtilla/cxre/state"
+)
+
 type Templates interface {
 	Templater
 	SwapTemplater(Templater)
+	SetMode(CacheMode) error
+	Precompile() error
+	RenderTo(io.Writer, string, interface{}, ...state.State) error
+	RenderString(string, interface{}, ...state.State) (string, error)
 }
 
 type templates struct {
@@ -18,3 +28,33 @@ func New(t Templater) Templates {
 func (t *templates) SwapTemplater(tr Templater) {
 	t.Templater = tr
 }
+
+// SetMode sets the CacheMode of a Cacher Templater and precompiles it, for an
+// app to call while it is configured with the CacheMode of its mode, so that
+// an app in production fails at boot on any template it cannot parse.
+func (t *templates) SetMode(m CacheMode) error {
+	if c, ok := t.Templater.(Cacher); ok {
+		c.SetCacheMode(m)
+	}
+	return t.Precompile()
+}
+
+// Precompile parses and caches every template at startup when the Templater
+// is a Cacher in Production mode, returning any template syntax error so an
+// app may fail fast; otherwise it does nothing.
+func (t *templates) Precompile() error {
+	if c, ok := t.Templater.(Cacher); ok && c.CacheMode() == Production {
+		return c.Precompile()
+	}
+	return nil
+}
+
+// RenderTo renders the named template to w outside of a request.
+func (t *templates) RenderTo(w io.Writer, name string, data interface{}, s ...state.State) error {
+	return RenderTo(t.Templater, w, name, data, s...)
+}
+
+// RenderString renders the named template to a string outside of a request.
+func (t *templates) RenderString(name string, data interface{}, s ...state.State) (string, error) {
+	return RenderString(t.Templater, name, data, s...)
+}